@@ -0,0 +1,90 @@
+package main
+
+import (
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// buildContext decides which files take part in generation, just as it
+// decides which files are compiled. GOOS, GOARCH and the -tags flag are
+// honoured.
+var buildContext = build.Default
+
+// matchFiles drops the files excluded by build constraints.
+func matchFiles(files []string) []string {
+	var matched []string
+	for _, file := range files {
+		match, err := buildContext.MatchFile(filepath.Dir(file), filepath.Base(file))
+		if err == nil && match {
+			matched = append(matched, file)
+		}
+	}
+	return matched
+}
+
+// fileConstraint returns the build constraint expression of a file, or an
+// empty string when it has none. Legacy // +build lines are converted.
+func fileConstraint(node *ast.File) string {
+	var goBuild constraint.Expr
+	var plusBuild []constraint.Expr
+
+	for _, group := range node.Comments {
+		if group.Pos() >= node.Package {
+			break
+		}
+		for _, comment := range group.List {
+			expr, err := constraint.Parse(comment.Text)
+			if err != nil {
+				continue
+			}
+			if constraint.IsGoBuild(comment.Text) {
+				goBuild = expr
+			} else {
+				plusBuild = append(plusBuild, expr)
+			}
+		}
+	}
+
+	switch {
+	case goBuild != nil:
+		return goBuild.String()
+	case len(plusBuild) > 0:
+		expr := plusBuild[0]
+		for _, next := range plusBuild[1:] {
+			expr = &constraint.AndExpr{X: expr, Y: next}
+		}
+		return expr.String()
+	}
+
+	return ""
+}
+
+// impliesConstraint reports whether a file name such as config_linux.go
+// restricts the file to a GOOS or GOARCH.
+func impliesConstraint(name string) bool {
+	ctx := build.Context{
+		GOOS:     "none",
+		GOARCH:   "none",
+		Compiler: "gc",
+		OpenFile: func(string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("package p\n")), nil
+		},
+	}
+	match, err := ctx.MatchFile("", name)
+	return err == nil && !match
+}
+
+// constrainedOutput names the output of a file restricted by build
+// constraints after it, so that files for different platforms or tags do not
+// overwrite each other. Ending the name like the source file also carries a
+// GOOS or GOARCH suffix over.
+func constrainedOutput(output string, file string) string {
+	if strings.HasSuffix(output, "_"+file+".go") {
+		return output
+	}
+	return strings.TrimSuffix(output, ".go") + "_" + file + ".go"
+}
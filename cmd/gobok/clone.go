@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+)
+
+// cloneWriter renders the statements of a generated Clone method. The method
+// starts from a shallow copy and only revisits the fields holding references.
+type cloneWriter struct {
+	folder *FolderData
+	scope  *scope
+	buf    strings.Builder
+	depth  int
+}
+
+// cloneBody returns the statements turning the shallow copy of the receiver
+// into a deep copy of it.
+func (f *FolderData) cloneBody(structType *types.Struct, typeParams *types.TypeParamList, idents Idents) string {
+	w := &cloneWriter{folder: f, scope: f.newScope(typeParams)}
+	w.scope.declare(idents.Self)
+	w.scope.declare(idents.Copy)
+
+	for i := 0; i < structType.NumFields(); i++ {
+		field := structType.Field(i)
+		if w.needsDeepCopy(field.Type()) {
+			w.copy(idents.Copy+"."+field.Name(), idents.Self+"."+field.Name(), field.Type())
+		}
+	}
+	return w.buf.String()
+}
+
+// cloneable reports whether t is a struct of the package that gets a
+// generated Clone method.
+func (f *FolderData) cloneable(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+
+	origin := named.Origin().Obj()
+	for _, builder := range f.Builders {
+		if builder.GenerateClone && builder.named.Obj() == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// needsDeepCopy reports whether a shallow copy of a value of type t would
+// share memory with the original. Locks can't be copied, so values holding
+// one, and pointers to them, are left shared.
+func (w *cloneWriter) needsDeepCopy(t types.Type) bool {
+	if _, ok := t.(*types.TypeParam); ok {
+		return false
+	}
+	if hasLock(t) {
+		return false
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		return !hasLock(u.Elem())
+	case *types.Map:
+		return !hasLock(u.Elem())
+	case *types.Slice:
+		return true
+	case *types.Array:
+		return w.needsDeepCopy(u.Elem())
+	}
+
+	return w.folder.cloneable(t)
+}
+
+// copy writes statements making dst a deep copy of src. dst must already
+// hold a shallow copy of src and must not be the same expression.
+func (w *cloneWriter) copy(dst string, src string, t types.Type) {
+	if clone, ok := w.cloneCall(src, t); ok {
+		w.printf("%s = %s\n", dst, clone)
+		return
+	}
+
+	w.depth++
+	defer func() { w.depth-- }()
+	v := w.scope.avoid(fmt.Sprintf("v%d", w.depth))
+
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		w.printf("if %s != nil {\n", src)
+		w.printf("%s := *%s\n", v, src)
+		if w.needsDeepCopy(u.Elem()) {
+			w.copy(v, "(*"+src+")", u.Elem())
+		}
+		w.printf("%s = &%s\n", dst, v)
+		w.printf("}\n")
+
+	case *types.Slice:
+		i := w.scope.avoid(fmt.Sprintf("i%d", w.depth))
+		w.printf("if %s != nil {\n", src)
+		w.printf("%s = make(%s, len(%s))\n", dst, w.folder.typeString(t), src)
+		if clone, ok := w.cloneCall(v, u.Elem()); ok {
+			w.printf("for %s, %s := range %s {\n", i, v, src)
+			w.printf("%s[%s] = %s\n", dst, i, clone)
+			w.printf("}\n")
+		} else if w.needsDeepCopy(u.Elem()) {
+			w.printf("for %s, %s := range %s {\n", i, v, src)
+			w.printf("%s[%s] = %s\n", dst, i, v)
+			w.copy(dst+"["+i+"]", v, u.Elem())
+			w.printf("}\n")
+		} else {
+			w.printf("copy(%s, %s)\n", dst, src)
+		}
+		w.printf("}\n")
+
+	case *types.Map:
+		k := w.scope.avoid(fmt.Sprintf("k%d", w.depth))
+		w.printf("if %s != nil {\n", src)
+		w.printf("%s = make(%s, len(%s))\n", dst, w.folder.typeString(t), src)
+		w.printf("for %s, %s := range %s {\n", k, v, src)
+		if clone, ok := w.cloneCall(v, u.Elem()); ok {
+			w.printf("%s[%s] = %s\n", dst, k, clone)
+		} else if w.needsDeepCopy(u.Elem()) {
+			// Map elements are not addressable, so they are copied through a variable
+			c := w.scope.avoid(fmt.Sprintf("c%d", w.depth))
+			w.printf("%s := %s\n", c, v)
+			w.copy(c, v, u.Elem())
+			w.printf("%s[%s] = %s\n", dst, k, c)
+		} else {
+			w.printf("%s[%s] = %s\n", dst, k, v)
+		}
+		w.printf("}\n")
+		w.printf("}\n")
+
+	case *types.Array:
+		i := w.scope.avoid(fmt.Sprintf("i%d", w.depth))
+		w.printf("for %s, %s := range %s {\n", i, v, src)
+		w.copy(dst+"["+i+"]", v, u.Elem())
+		w.printf("}\n")
+	}
+}
+
+// cloneCall returns the expression copying src through a generated Clone
+// method, available for annotated structs and pointers to them.
+func (w *cloneWriter) cloneCall(src string, t types.Type) (string, bool) {
+	if w.folder.cloneable(t) {
+		return "*" + src + ".Clone()", true
+	}
+	if pointer, ok := t.Underlying().(*types.Pointer); ok && w.folder.cloneable(pointer.Elem()) {
+		return src + ".Clone()", true
+	}
+	return "", false
+}
+
+func (w *cloneWriter) printf(format string, args ...any) {
+	fmt.Fprintf(&w.buf, format, args...)
+}
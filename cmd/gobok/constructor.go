@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// ConstructorData describes one generated constructor.
+type ConstructorData struct {
+	Name     string
+	Type     string      // Constructed type, with type arguments
+	Params   []FieldData // Fields taken as parameters, in order, with Param set
+	Defaults []FieldData // Fields left out of the parameters that have a default
+	Pointer  bool        // Returns *T instead of T
+
+	// Validating constructors return (*T, error) after running the checks
+	// and the Validate method of the struct, if it has one
+	Validate          bool
+	Checks            []CheckData
+	HasValidateMethod bool
+	ErrorsPkg         string
+	Self              string // Identifiers of the constructed value and the errors
+	Errs              string
+	Err               string
+}
+
+// Result returns the result list of the constructor.
+func (c ConstructorData) Result() string {
+	switch {
+	case c.Validate:
+		return "(*" + c.Type + ", error)"
+	case c.Pointer:
+		return "*" + c.Type
+	}
+	return c.Type
+}
+
+// collectConstructors fills in the constructors of the builder from its
+// //gobok:constructor directives. Each directive declares one constructor and
+// may carry options separated by colons:
+//
+//	//gobok:constructor:name=NewUser:fields=Name,Email
+//	//gobok:constructor:exclude=cache,mu
+//	//gobok:constructor:name=NewServer:pointer
+//	//gobok:constructor:validate
+func (f *FolderData) collectConstructors(builder *BuilderData, structType *types.Struct) error {
+	names := make(map[string]bool)
+	for _, options := range builder.constructorOptions {
+		constructor, err := f.constructor(builder, structType, options)
+		if err != nil {
+			return err
+		}
+
+		if names[constructor.Name] {
+			return fmt.Errorf("constructor %s is declared twice", constructor.Name)
+		}
+		names[constructor.Name] = true
+
+		builder.Constructors = append(builder.Constructors, constructor)
+	}
+
+	return nil
+}
+
+// constructor builds one constructor from the options of its directive.
+func (f *FolderData) constructor(builder *BuilderData, structType *types.Struct, options string) (ConstructorData, error) {
+	constructor := ConstructorData{Name: "New" + builder.BuilderName, Type: builder.StructName + builder.TypeArgs}
+
+	var selected, excluded []string
+	selecting := false
+	if options != "" {
+		for _, option := range strings.Split(strings.TrimPrefix(options, ":"), ":") {
+			key, value, hasValue := strings.Cut(option, "=")
+			switch key {
+			case "pointer", "validate":
+				if hasValue {
+					return constructor, fmt.Errorf("constructor option %s takes no value", key)
+				}
+				constructor.Pointer = true
+				constructor.Validate = constructor.Validate || key == "validate"
+			case "name":
+				if !token.IsIdentifier(value) {
+					return constructor, fmt.Errorf("invalid constructor name %q", value)
+				}
+				constructor.Name = value
+			case "fields":
+				selected, selecting = splitNames(value), true
+			case "exclude":
+				excluded = splitNames(value)
+			default:
+				return constructor, fmt.Errorf("unknown constructor option %q", option)
+			}
+		}
+	}
+
+	fields := make(map[string]FieldData)
+	for _, field := range builder.Fields {
+		fields[field.Name] = field
+	}
+
+	params := builder.Fields
+	if selecting {
+		params = nil
+		seen := make(map[string]bool)
+		for _, name := range selected {
+			field, ok := fields[name]
+			if !ok {
+				return constructor, fmt.Errorf("constructor %s: %s", constructor.Name, unknownField(structType, name))
+			}
+			if seen[name] {
+				return constructor, fmt.Errorf("constructor %s: field %s is listed twice", constructor.Name, name)
+			}
+			seen[name] = true
+			params = append(params, field)
+		}
+	}
+
+	skip := make(map[string]bool)
+	for _, name := range excluded {
+		if !hasField(structType, name) {
+			return constructor, fmt.Errorf("constructor %s: no field %s to exclude", constructor.Name, name)
+		}
+		skip[name] = true
+	}
+
+	// The errors package, and any the checks need, are registered before
+	// an identifier is allocated, so that none of them shadows an import
+	if constructor.Validate {
+		constructor.ErrorsPkg = f.importName("errors", "errors")
+		constructor.HasValidateMethod = hasValidateMethod(builder.named)
+
+		checks, err := f.collectChecks(builder.StructName, structType)
+		if err != nil {
+			return constructor, err
+		}
+		constructor.Checks = checks
+	}
+
+	s := f.newScope(builder.named.TypeParams())
+	taken := make(map[string]bool)
+	for _, field := range params {
+		if skip[field.Name] {
+			continue
+		}
+		field.Param = s.declare(lowerFirst(field.Name))
+		constructor.Params = append(constructor.Params, field)
+		taken[field.Name] = true
+	}
+
+	for _, field := range builder.Fields {
+		if !taken[field.Name] && field.Default != "" {
+			constructor.Defaults = append(constructor.Defaults, field)
+		}
+	}
+
+	if constructor.Validate {
+		constructor.Self = s.declare("s")
+		constructor.Errs = s.declare("errs")
+		constructor.Err = s.declare("err")
+	}
+
+	return constructor, nil
+}
+
+// splitNames splits a comma-separated list of field names.
+func splitNames(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func hasField(structType *types.Struct, name string) bool {
+	for i := 0; i < structType.NumFields(); i++ {
+		if structType.Field(i).Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownField explains why a field named in a directive is not available.
+func unknownField(structType *types.Struct, name string) string {
+	if hasField(structType, name) {
+		return fmt.Sprintf("field %s is excluded from generation, tag it with gobok:\"include\"", name)
+	}
+	return fmt.Sprintf("no field %s", name)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// defaultValue type-checks the default declared for a field as if it were
+// written next to the field, verifies it is assignable to the field and
+// renders it with the package names used by the generated file.
+func (f *FolderData) defaultValue(fset *token.FileSet, field *types.Var, value string) (string, error) {
+	expr, err := parser.ParseExpr(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid default %q: %w", value, err)
+	}
+
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	if err := types.CheckExpr(fset, f.Types, field.Pos(), expr, info); err != nil {
+		return "", fmt.Errorf("invalid default %q: %w", value, err)
+	}
+
+	tv := info.Types[expr]
+	if !types.AssignableTo(tv.Type, field.Type()) || !representable(tv, field.Type()) {
+		return "", fmt.Errorf("default %q (%s) is not assignable to %s", value, tv.Type, field.Type())
+	}
+
+	// Spell package-qualified identifiers the way the generated file imports them
+	expr = astutil.Apply(expr, func(c *astutil.Cursor) bool {
+		switch node := c.Node().(type) {
+		case *ast.SelectorExpr:
+			ident, ok := node.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if pkgName, ok := info.Uses[ident].(*types.PkgName); ok {
+				c.Replace(&ast.SelectorExpr{
+					X:   ast.NewIdent(f.qualifier(pkgName.Imported())),
+					Sel: node.Sel,
+				})
+				return false
+			}
+		case *ast.Ident:
+			// Identifiers brought in by a dot-import must be qualified
+			obj := info.Uses[node]
+			if obj == nil || obj.Pkg() == nil || obj.Pkg() == f.Types || obj.Parent() != obj.Pkg().Scope() {
+				return true
+			}
+			c.Replace(&ast.SelectorExpr{
+				X:   ast.NewIdent(f.qualifier(obj.Pkg())),
+				Sel: ast.NewIdent(node.Name),
+			})
+		}
+		return true
+	}, nil).(ast.Expr)
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), expr); err != nil {
+		return "", fmt.Errorf("invalid default %q: %w", value, err)
+	}
+
+	return buf.String(), nil
+}
+
+// representable reports whether a constant default fits the basic type
+// underlying t, which types.AssignableTo does not check.
+func representable(tv types.TypeAndValue, t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok || tv.Value == nil {
+		return true
+	}
+
+	var literal string
+	switch tv.Value.Kind() {
+	case constant.Int:
+		literal = tv.Value.ExactString()
+	case constant.Float:
+		value, _ := constant.Float64Val(tv.Value)
+		literal = strconv.FormatFloat(value, 'g', -1, 64)
+	default:
+		return true
+	}
+
+	// Converting the constant in the universe scope makes go/types report
+	// overflows and truncations for us
+	conversion, err := parser.ParseExpr(basic.Name() + "(" + literal + ")")
+	if err != nil {
+		return false
+	}
+	return types.CheckExpr(token.NewFileSet(), nil, token.NoPos, conversion, nil) == nil
+}
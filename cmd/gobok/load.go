@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// processPackages loads and type-checks the source files of the given
+// directories, each directory being one package, and collects builder data
+// for every annotated struct they declare. The directories of a module are
+// loaded together, with the types of their dependencies read from export
+// data. Previously generated files are loaded with nothing but their package
+// clause, so that a stale one never takes part in type checking.
+func processPackages(dirs []string, sources, generated map[string][]string) {
+	var roots []string
+	modules := make(map[string][]string)
+	hidden := make(map[string]bool)
+
+	for _, dir := range dirs {
+		files := matchFiles(sources[dir])
+		if len(files) == 0 {
+			continue
+		}
+
+		for _, file := range files {
+			logf("Scanning file: %s", file)
+		}
+		for _, file := range generated[dir] {
+			hidden[file] = true
+		}
+
+		root := moduleRoot(dir)
+		if root == "" {
+			// Outside of a module, files can only be loaded as an ad-hoc
+			// package of their own directory
+			loadPackages(dir, files, hidden)
+			continue
+		}
+		if modules[root] == nil {
+			roots = append(roots, root)
+		}
+		modules[root] = append(modules[root], dir)
+	}
+
+	for _, root := range roots {
+		loadPackages(root, modules[root], hidden)
+	}
+}
+
+// loadPackages loads the packages matching patterns from dir and collects
+// their builder data. Hidden files are replaced by their package clause.
+func loadPackages(dir string, patterns []string, hidden map[string]bool) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+			packages.NeedImports | packages.NeedTypes | packages.NeedTypesInfo,
+		Dir:     dir,
+		Fset:    token.NewFileSet(),
+		Env:     append(os.Environ(), "GOOS="+buildContext.GOOS, "GOARCH="+buildContext.GOARCH),
+		Overlay: make(map[string][]byte),
+	}
+	if len(buildContext.BuildTags) > 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(buildContext.BuildTags, ",")}
+	}
+	for file := range hidden {
+		if clause, err := packageClause(file); err == nil {
+			cfg.Overlay[file] = clause
+		}
+	}
+
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		reportError(token.Position{Filename: dir}, "failed to load packages: %v", err)
+		return
+	}
+
+	for _, pkg := range pkgs {
+		// Type errors are tolerated: code in the package commonly refers to
+		// builders that have not been generated yet. For the same reason the
+		// package may not build, which is reported once more as the output
+		// of the compiler. Type errors in the fields of an annotated struct
+		// are reported along with the struct.
+		failed := false
+		for _, pkgErr := range pkg.Errors {
+			compiler := pkgErr.Kind == packages.ListError && strings.HasPrefix(pkgErr.Msg, "# "+pkg.PkgPath+"\n")
+			if pkgErr.Kind != packages.TypeError && !compiler {
+				reportError(token.Position{}, "%v", pkgErr)
+				failed = true
+			}
+		}
+		if failed || pkg.Types == nil {
+			continue
+		}
+
+		var files []*ast.File
+		for _, file := range pkg.Syntax {
+			if !hidden[pkg.Fset.Position(file.Package).Filename] {
+				files = append(files, file)
+			}
+		}
+
+		prefix, err := packagePrefix(pkg.Fset, files)
+		if err != nil {
+			reportError(token.Position{}, "%v", err)
+			continue
+		}
+
+		folder := folders[pkg.Dir]
+		if folder == nil {
+			folder = &FolderData{
+				PackageName: pkg.Types.Name(),
+				Imports:     make(map[string]ImportData),
+				HasBuilders: false,
+				Types:       pkg.Types,
+				OptionNames: make(map[string]string),
+				Prefix:      prefix,
+				methods:     declaredMethods(files),
+			}
+			folders[pkg.Dir] = folder
+		}
+		folder.typeErrors = pkg.TypeErrors
+
+		for _, file := range files {
+			processFile(folder, pkg, file)
+		}
+
+		// Clone methods and nested builders are resolved once every
+		// annotated struct of the package is known, so that they can refer
+		// to each other
+		for i, builder := range folder.Builders {
+			if builder.GenerateClone {
+				structType := builder.named.Underlying().(*types.Struct)
+				folder.Builders[i].CloneBody = folder.cloneBody(structType, builder.named.TypeParams(), builder.Idents)
+			}
+			if builder.GenerateBuilder {
+				folder.linkNestedBuilders(&folder.Builders[i])
+			}
+		}
+	}
+}
+
+// moduleRoot returns the directory of the go.mod file dir belongs to, or an
+// empty string when it is not part of a module.
+func moduleRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// packageClause returns the source of a file up to the end of its package
+// clause, which keeps its build constraints.
+func packageClause(file string) ([]byte, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, file, src, parser.PackageClauseOnly)
+	if err != nil {
+		return nil, err
+	}
+	end := fset.Position(node.Name.End()).Offset
+	return append(src[:end:end], '\n'), nil
+}
+
+// invalidType reports whether t is, or is built from, a type that failed to
+// type-check, such as an undefined name.
+func invalidType(t types.Type) bool {
+	switch t := types.Unalias(t).(type) {
+	case *types.Basic:
+		return t.Kind() == types.Invalid
+	case *types.Pointer:
+		return invalidType(t.Elem())
+	case *types.Slice:
+		return invalidType(t.Elem())
+	case *types.Array:
+		return invalidType(t.Elem())
+	case *types.Chan:
+		return invalidType(t.Elem())
+	case *types.Map:
+		return invalidType(t.Key()) || invalidType(t.Elem())
+	case *types.Signature:
+		return invalidTuple(t.Params()) || invalidTuple(t.Results())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if invalidType(t.Field(i).Type()) {
+				return true
+			}
+		}
+	case *types.Named:
+		// Declared types are only checked at the surface, which keeps
+		// recursive types from looping
+		if basic, ok := t.Underlying().(*types.Basic); ok && basic.Kind() == types.Invalid {
+			return true
+		}
+		for arg := range t.TypeArgs().Types() {
+			if invalidType(arg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func invalidTuple(tuple *types.Tuple) bool {
+	for v := range tuple.Variables() {
+		if invalidType(v.Type()) {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidFieldError returns the error of a field whose type failed to
+// type-check, quoting the type error found on the field's line.
+func (f *FolderData) invalidFieldError(fset *token.FileSet, field *types.Var) error {
+	pos := fset.Position(field.Pos())
+	for _, typeErr := range f.typeErrors {
+		errPos := typeErr.Fset.Position(typeErr.Pos)
+		if errPos.Filename == pos.Filename && errPos.Line == pos.Line {
+			return &fieldError{pos: errPos, err: fmt.Errorf("field %s: %s", field.Name(), typeErr.Msg)}
+		}
+	}
+	return &fieldError{pos: pos, err: fmt.Errorf("field %s has an invalid type", field.Name())}
+}
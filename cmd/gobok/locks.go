@@ -0,0 +1,67 @@
+package main
+
+import (
+	"go/types"
+	"slices"
+)
+
+// locker is the method set of sync.Locker.
+var locker = func() *types.Interface {
+	signature := types.NewSignatureType(nil, nil, nil, nil, nil, false)
+	return types.NewInterfaceType([]*types.Func{
+		types.NewFunc(0, nil, "Lock", signature),
+		types.NewFunc(0, nil, "Unlock", signature),
+	}, nil).Complete()
+}()
+
+// hasLock reports whether values of t hold a lock, such as a sync.Mutex,
+// a sync.WaitGroup or anything else marked with a noCopy field. vet reports
+// every copy of such a value, so generated code must never make one.
+func hasLock(t types.Type) bool {
+	if _, ok := t.(*types.TypeParam); ok {
+		return false
+	}
+	// Types that failed to type-check implement every interface
+	if basic, ok := t.Underlying().(*types.Basic); ok && basic.Kind() == types.Invalid {
+		return false
+	}
+
+	// Like vet, a lock is a type whose pointer is a sync.Locker while the
+	// type itself is not
+	if types.Implements(types.NewPointer(t), locker) && !types.Implements(t, locker) {
+		return true
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if hasLock(u.Field(i).Type()) {
+				return true
+			}
+		}
+	case *types.Array:
+		return hasLock(u.Elem())
+	}
+	return false
+}
+
+// copiesLock reports whether a builder made from an existing instance would
+// copy a lock, held either by the struct itself or by an embedded struct
+// that its promoted setters write through, which the builder copies too.
+func (b *BuilderData) copiesLock(structType *types.Struct) bool {
+	if hasLock(b.named) {
+		return true
+	}
+
+	embedded := b.EmbeddedPointers()
+	for i := 0; i < structType.NumFields(); i++ {
+		field := structType.Field(i)
+		if !field.Embedded() || !slices.Contains(embedded, field.Name()) {
+			continue
+		}
+		if pointer, ok := field.Type().(*types.Pointer); ok && hasLock(pointer.Elem()) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,17 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"go/ast"
 	"go/format"
-	"go/parser"
 	"go/token"
+	"go/types"
+	"io"
 	"io/fs"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
 	"text/template"
+	"unicode"
+
+	"golang.org/x/tools/go/packages"
 )
 
 const toolVersion = "v1.0.0"
@@ -22,24 +33,215 @@ type BuilderData struct {
 	Fields              []FieldData
 	GenerateBuilder     bool
 	GenerateConstructor bool
-	ConstructorName     string
+	Constructors        []ConstructorData
+	GenerateOptions     bool // Functional options: <Struct>Option, With<Field> and New<Struct>
+	GenerateClone       bool // Deep-copying Clone method
+	CloneBody           string
+	TypeParams          string // Type parameter list with constraints, e.g. "[K comparable, V any]"
+	TypeArgs            string // Type parameter names only, e.g. "[K, V]"
+	Staged              bool   // Required fields are set through a chain of step interfaces
+	Immutable           bool   // Setters work on copies so that builders can serve as prototypes
+	Flatten             bool   // Promoted fields of embedded structs get setters too
+	Private             bool   // Builder types and functions are unexported
+	Promoted            []FieldData
+	Validate            bool // Build validates the instance and returns an error
+	Checks              []CheckData
+	HasValidateMethod   bool   // The struct declares `Validate() error`
+	GenerateFrom        bool   // The struct holds no lock, so that instances can be copied into a builder
+	GenerateToBuilder   bool   // The struct has no member named like the ToBuilder method
+	ErrorsPkg           string // Name the errors package is imported under
+	SetterPrefix        string // Prepended to setter names, such as With or Set
+	Idents              Idents // Identifiers declared by the generated functions
+
+	named      *types.Named          // The annotated struct
+	fieldTypes map[string]types.Type // Types of the fields, by name
+	output     string                // Name of the file the code is generated into
+	reserved   map[string]bool       // Builder methods setters may not be named like
+
+	constructorOptions []string // Options of each //gobok:constructor directive
+	constraint         string   // Build constraint of the file the struct is declared in
 }
 
 type FieldData struct {
 	Name       string
-	SetterName string // Capitalized version of Name
+	SetterName string // Capitalized version of Name, after the setter prefix
 	Type       string
+	Param      string // Constructor parameter
+	Required   bool   // Tagged with gobok:"required"
+	Default    string // Expression the builder initialises the field with
+
+	Embedded     string // Embedded field a promoted field is set through
+	EmbeddedType string // Type allocated when the embedded field is a nil pointer
+
+	// Element types driving the convenience setters, empty when the field
+	// gets none
+	Elem    string // Slice element, appended by Add<Field>
+	Key     string // Map key, stored by Put<Field>
+	Value   string // Map value, stored by Put<Field>
+	Pointee string // Pointed-to type, set by <Field>Value
+
+	Nested        string // Builder of the field's struct type, used by <Field>With
+	NestedFrom    string // Function copying a value into that builder
+	NestedPointer bool   // The field points to the nested struct
+}
+
+// AddName returns the name of the setter appending to a slice field.
+func (f FieldData) AddName() string {
+	return "Add" + capitalizeFirst(f.Name)
+}
+
+// PutName returns the name of the setter storing an entry of a map field.
+func (f FieldData) PutName() string {
+	return "Put" + capitalizeFirst(f.Name)
+}
+
+// ValueName returns the name of the setter taking the value a pointer field
+// points to.
+func (f FieldData) ValueName() string {
+	return capitalizeFirst(f.Name) + "Value"
+}
+
+// OptionName returns the name of the functional option setting the field.
+func (f FieldData) OptionName() string {
+	return "With" + capitalizeFirst(f.Name)
+}
+
+// StepData describes one step interface of a staged builder.
+type StepData struct {
+	Field FieldData
+	Name  string // Name of the step interface
+	Next  string // Type returned by the step's setter
+	Last  bool   // The last step hands over to the builder
+}
+
+// Steps returns the step interfaces of a staged builder, one per required
+// field, each leading to the next and the last one to the builder itself.
+func (b BuilderData) Steps() []StepData {
+	if !b.Staged {
+		return nil
+	}
+
+	var steps []StepData
+	for _, field := range b.Fields {
+		if !field.Required {
+			continue
+		}
+		step := StepData{
+			Field: field,
+			Name:  b.StructName + capitalizeFirst(field.Name) + "Step",
+		}
+		if b.Private {
+			step.Name = lowerFirst(step.Name)
+		}
+		if len(steps) > 0 {
+			steps[len(steps)-1].Next = step.Name + b.TypeArgs
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) > 0 {
+		steps[len(steps)-1].Next = b.BuilderRef()
+		steps[len(steps)-1].Last = true
+	}
+
+	return steps
+}
+
+// BuilderRef returns the type builder methods are declared on and return:
+// a pointer for regular builders and a value for immutable ones.
+func (b BuilderData) BuilderRef() string {
+	return b.Deref() + b.BuilderType() + b.TypeArgs
+}
+
+// BuilderType returns the name of the builder type, unexported for private
+// builders.
+func (b BuilderData) BuilderType() string {
+	if b.Private {
+		return lowerFirst(b.StructName) + "Builder"
+	}
+	return b.StructName + "Builder"
+}
+
+// NewBuilderName returns the name of the function creating a builder.
+func (b BuilderData) NewBuilderName() string {
+	if b.Private {
+		return "new" + b.BuilderName + "Builder"
+	}
+	return "New" + b.BuilderName + "Builder"
+}
+
+// Deref returns the pointer marker of the builder's types, if any.
+func (b BuilderData) Deref() string {
+	if b.Immutable {
+		return ""
+	}
+	return "*"
+}
+
+// AddrOf returns the address operator taken on the builder's values, if any.
+func (b BuilderData) AddrOf() string {
+	if b.Immutable {
+		return ""
+	}
+	return "&"
+}
+
+// ToBuilderName returns the name of the method turning an instance back into
+// a builder.
+func (b BuilderData) ToBuilderName() string {
+	if b.Private {
+		return "toBuilder"
+	}
+	return "ToBuilder"
+}
+
+// EmbeddedPointers returns the embedded pointer fields that promoted setters
+// write through, once each.
+func (b BuilderData) EmbeddedPointers() []string {
+	var names []string
+	for _, field := range b.Promoted {
+		if field.EmbeddedType != "" && !slices.Contains(names, field.Embedded) {
+			names = append(names, field.Embedded)
+		}
+	}
+	return names
+}
+
+// Setters returns the fields that get a setter on the builder type. Required
+// fields of a staged builder are only settable through their step.
+func (b BuilderData) Setters() []FieldData {
+	if !b.Staged {
+		return b.Fields
+	}
+
+	var setters []FieldData
+	for _, field := range b.Fields {
+		if !field.Required {
+			setters = append(setters, field)
+		}
+	}
+
+	return setters
 }
 
 type FolderData struct {
 	PackageName string
 	Builders    []BuilderData
-	Imports     map[string]string
-	HasBuilders bool // Track if this directory has any builders
+	Imports     map[string]ImportData // Keyed by import path
+	HasBuilders bool                  // Track if this directory has any builders
+	Types       *types.Package        // Type-checked package the builders belong to
+	OptionNames map[string]string     // Package-level option functions generated so far, mapped to their struct
+	Prefix      string                // Setter prefix configured for the package
+
+	methods    map[string]map[string]bool // Methods declared by hand, by receiver type name
+	typeErrors []types.Error              // Errors of the type-checked package
 }
 
 var folders = make(map[string]*FolderData)
 
+// dryRun lists the files that would be written or removed instead of
+// touching them.
+var dryRun bool
+
 type ImportData struct {
 	Alias string
 	Path  string
@@ -47,23 +249,57 @@ type ImportData struct {
 
 type TemplateData struct {
 	PackageName string
+	Constraint  string // Expression of the //go:build line, if any
 	Builders    []BuilderData
 	ToolVersion string
 	Imports     []ImportData
 }
 
 func main() {
+	check := flag.Bool("check", false, "report stale generated files with a diff instead of writing them, exiting with status 1 if any")
+	prune := flag.Bool("prune", false, "remove generated files that no longer have annotated structs, or list them with -check")
+	keepGoing := flag.Bool("keep-going", false, "write the code that could be generated despite errors and exit with status 0")
+	flag.BoolVar(&dryRun, "n", false, "list the files that would be written or removed without touching them")
+	flag.BoolVar(&dryRun, "dry-run", false, "same as -n")
+	toStdout := flag.Bool("stdout", false, "print the generated code of a single package to stdout instead of writing it")
+	flag.StringVar(&setterPrefix, "prefix", "", "prefix of setter names, such as With or Set")
+	tags := flag.String("tags", "", "comma-separated list of additional build tags to honour")
+	flag.StringVar(&outputPattern, "output", defaultOutput, "name template of the generated files, e.g. {{.Package}}_gobok.go, {{.File}}_gobok.go or {{.Struct | snake}}_builder.go")
 	flag.Parse()
+	if *tags != "" {
+		buildContext.BuildTags = strings.Split(*tags, ",")
+	}
+	if _, err := parseOutputPattern(outputPattern); err != nil {
+		reportError(token.Position{}, "invalid -output: %v", err)
+		os.Exit(1)
+	}
+	if !validPrefix(setterPrefix) {
+		reportError(token.Position{}, "invalid -prefix %q", setterPrefix)
+		os.Exit(1)
+	}
+	if *check && dryRun || *check && *toStdout || dryRun && *toStdout {
+		reportError(token.Position{}, "-check, -n and -stdout are mutually exclusive")
+		os.Exit(1)
+	}
+	if *toStdout {
+		logOutput = os.Stderr
+	}
 	roots := flag.Args()
 	if len(roots) == 0 {
 		roots = []string{"."}
 	}
 
+	// Source and previously generated files grouped by directory, each
+	// directory being one package
+	sources := make(map[string][]string)
+	generated := make(map[string][]string)
+	var dirs []string
+
 	for _, root := range roots {
 		// Get absolute path
 		absRoot, err := filepath.Abs(root)
 		if err != nil {
-			fmt.Printf("Error getting absolute path for %s: %v\n", root, err)
+			reportError(token.Position{}, "failed to get absolute path for %s: %v", root, err)
 			continue
 		}
 
@@ -80,207 +316,797 @@ func main() {
 				return nil
 			}
 
-			if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") || filepath.Base(path) == "gobok.go" {
+			if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
 				return nil
 			}
 
-			processFile(path)
+			dir := filepath.Dir(path)
+			if sources[dir] == nil && generated[dir] == nil {
+				dirs = append(dirs, dir)
+			}
+			if isGenerated(path) {
+				generated[dir] = append(generated[dir], path)
+			} else {
+				sources[dir] = append(sources[dir], path)
+			}
 			return nil
 		})
 
 		if err != nil {
-			fmt.Printf("Error walking %s: %v\n", root, err)
+			reportError(token.Position{}, "failed to walk %s: %v", root, err)
 		}
 	}
 
-	for folder, data := range folders {
-		writeBuilders(folder, data)
+	processPackages(dirs, sources, generated)
+
+	// A partial run would leave the generated files out of step with each
+	// other, so nothing is generated once something failed
+	if failures > 0 && !*keepGoing {
+		os.Exit(1)
 	}
-}
 
-func processFile(path string) {
-	fmt.Printf("[gobok] Scanning file: %s\n", path)
+	if *toStdout {
+		var withBuilders []string
+		for _, dir := range dirs {
+			if data := folders[dir]; data != nil && data.HasBuilders {
+				withBuilders = append(withBuilders, dir)
+			}
+		}
+		if len(withBuilders) != 1 {
+			reportError(token.Position{}, "-stdout needs exactly one package with annotated structs, found %d", len(withBuilders))
+			os.Exit(1)
+		}
 
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
-	if err != nil {
-		fmt.Printf("Failed to parse %s: %v\n", path, err)
+		printBuilders(os.Stdout, withBuilders[0], folders[withBuilders[0]])
+		if failures > 0 && !*keepGoing {
+			os.Exit(1)
+		}
 		return
 	}
 
-	folder := filepath.Dir(path)
-	if folders[folder] == nil {
-		folders[folder] = &FolderData{
-			PackageName: node.Name.Name,
-			Imports:     make(map[string]string),
-			HasBuilders: false,
+	stale := false
+	var outputs []outputFile
+	for _, dir := range dirs {
+		data := folders[dir]
+		if data == nil {
+			continue
 		}
-	}
 
-	// Create a map of original imports for reference
-	originalImports := make(map[string]string)
-	for _, imp := range node.Imports {
-		importPath := strings.Trim(imp.Path.Value, "\"")
-		if imp.Name != nil {
-			originalImports[imp.Name.Name] = importPath
+		if *check {
+			stale = !checkBuilders(dir, data) || stale
 		} else {
-			parts := strings.Split(importPath, "/")
-			originalImports[parts[len(parts)-1]] = importPath
+			outputs = append(outputs, generateOutputs(dir, data)...)
+		}
+	}
+
+	// For the same reason, nothing is written unless every file was
+	// generated
+	if failures > 0 && !*keepGoing {
+		os.Exit(1)
+	}
+	writeOutputs(outputs)
+
+	// Which files are still generated is only known for certain when every
+	// struct made it through
+	if failures == 0 {
+		for _, dir := range dirs {
+			stale = !handleOrphans(staleOutputs(folders[dir], generated[dir]), *check, *prune) || stale
 		}
 	}
 
+	if stale || (failures > 0 && !*keepGoing) {
+		os.Exit(1)
+	}
+}
+
+// handleOrphans removes, or reports, generated files that no longer
+// correspond to any annotated struct. In check mode they are only listed,
+// and it returns false when there are any.
+func handleOrphans(files []string, check, prune bool) bool {
+	for _, file := range files {
+		switch {
+		case check && prune:
+			logf("Stale file: %s (would be removed)", file)
+		case check:
+			logf("Stale file: %s (no longer generated, run with -prune to remove it)", file)
+		case prune && dryRun:
+			logf("Would remove file: %s", file)
+		case prune:
+			removeOutput(file)
+		default:
+			reportWarning(token.Position{Filename: file}, "no longer generated, run with -prune to remove it")
+		}
+	}
+	return !check || len(files) == 0
+}
+
+// processFile collects builder data for the annotated structs of one file.
+func processFile(folder *FolderData, pkg *packages.Package, node *ast.File) {
+	fileName := strings.TrimSuffix(filepath.Base(pkg.Fset.Position(node.Pos()).Filename), ".go")
+	buildConstraint := fileConstraint(node)
+
 	for _, decl := range node.Decls {
 		genDecl, ok := decl.(*ast.GenDecl)
 		if !ok || genDecl.Tok != token.TYPE {
 			continue
 		}
 
-		if genDecl.Doc == nil {
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+
+			// A directive on a grouped declaration applies to every struct
+			// in the group, alongside the directives of the struct itself
+			var comments []*ast.Comment
+			for _, doc := range []*ast.CommentGroup{genDecl.Doc, typeSpec.Doc} {
+				if doc != nil {
+					comments = append(comments, doc.List...)
+				}
+			}
+
+			builder := BuilderData{SetterPrefix: folder.Prefix}
+			output := outputPattern
+
+			for _, comment := range comments {
+				text := strings.TrimSpace(comment.Text)
+
+				switch {
+				case text == "//gobok:builder":
+					builder.GenerateBuilder = true
+					folder.HasBuilders = true
+				case text == "//gobok:builder:staged":
+					builder.GenerateBuilder = true
+					builder.Staged = true
+					folder.HasBuilders = true
+				case text == "//gobok:builder:immutable":
+					builder.GenerateBuilder = true
+					builder.Immutable = true
+					folder.HasBuilders = true
+				case text == "//gobok:builder:flatten":
+					builder.GenerateBuilder = true
+					builder.Flatten = true
+					folder.HasBuilders = true
+				case text == "//gobok:builder:private":
+					builder.GenerateBuilder = true
+					builder.Private = true
+					folder.HasBuilders = true
+				case text == "//gobok:builder:validate":
+					builder.GenerateBuilder = true
+					builder.Validate = true
+					folder.HasBuilders = true
+				case text == "//gobok:options":
+					builder.GenerateOptions = true
+					folder.HasBuilders = true
+				case text == "//gobok:clone":
+					builder.GenerateClone = true
+					folder.HasBuilders = true
+				case text == "//gobok:constructor" || strings.HasPrefix(text, "//gobok:constructor:"):
+					builder.GenerateConstructor = true
+					builder.constructorOptions = append(builder.constructorOptions, strings.TrimPrefix(text, "//gobok:constructor"))
+					folder.HasBuilders = true
+				case strings.HasPrefix(text, "//gobok:builder:prefix="):
+					builder.GenerateBuilder = true
+					builder.SetterPrefix = strings.TrimPrefix(text, "//gobok:builder:prefix=")
+					folder.HasBuilders = true
+				case strings.HasPrefix(text, "//gobok:output="):
+					output = strings.TrimPrefix(text, "//gobok:output=")
+				}
+			}
+
+			if !builder.GenerateBuilder && !builder.GenerateConstructor && !builder.GenerateOptions && !builder.GenerateClone {
+				continue
+			}
+
+			// Aliases denote a struct declared elsewhere
+			typeName, ok := pkg.TypesInfo.Defs[typeSpec.Name].(*types.TypeName)
+			if !ok || typeName.IsAlias() {
+				continue
+			}
+
+			named, ok := typeName.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+
+			if _, ok := named.Underlying().(*types.Struct); !ok {
+				continue
+			}
+
+			// Imports registered for a struct that fails are dropped with it
+			imports := maps.Clone(folder.Imports)
+
+			builder.named = named
+			builder.StructName = typeName.Name()
+			builder.BuilderName = capitalizeFirst(builder.StructName)
+			builder.TypeParams, builder.TypeArgs = folder.typeParamsToString(named.TypeParams())
+			builder.constraint = buildConstraint
+
+			pos := pkg.Fset.Position(typeSpec.Pos())
+			if err := folder.collectBuilder(pkg.Fset, pos, &builder, output, fileName); err != nil {
+				var fieldErr *fieldError
+				if errors.As(err, &fieldErr) {
+					pos = fieldErr.pos
+				}
+				reportError(pos, "failed to generate code for %s: %v", builder.StructName, err)
+				folder.Imports = imports
+				continue
+			}
+
+			// A Clone method skipped for a lock may leave nothing to generate
+			if !builder.GenerateBuilder && !builder.GenerateConstructor && !builder.GenerateOptions && !builder.GenerateClone {
+				folder.Imports = imports
+				continue
+			}
+
+			folder.Builders = append(folder.Builders, builder)
+		}
+	}
+}
+
+// collectBuilder fills in the data generated for an annotated struct, whose
+// directives and type are already recorded in builder. The output file is
+// named from the output template and the name of the declaring file.
+func (f *FolderData) collectBuilder(fset *token.FileSet, pos token.Position, builder *BuilderData, output, fileName string) error {
+	named := builder.named
+	structType := named.Underlying().(*types.Struct)
+
+	name, err := outputName(output, OutputData{Package: f.PackageName, File: fileName, Struct: builder.StructName})
+	if err != nil {
+		return err
+	}
+	if builder.constraint != "" || impliesConstraint(fileName+".go") {
+		name = constrainedOutput(name, fileName)
+	}
+	builder.output = name
+
+	if !validPrefix(builder.SetterPrefix) {
+		return fmt.Errorf("invalid setter prefix %q", builder.SetterPrefix)
+	}
+	builder.reserved = f.reservedMethods(builder)
+
+	if err := f.collectFields(fset, builder, structType); err != nil {
+		return err
+	}
+
+	if builder.GenerateConstructor {
+		if err := f.collectConstructors(builder, structType); err != nil {
+			return err
+		}
+	}
+
+	if builder.GenerateBuilder {
+		if err := builder.checkSetterNames(); err != nil {
+			return err
+		}
+	}
+
+	if builder.GenerateOptions {
+		if err := f.claimOptionNames(*builder); err != nil {
+			return err
+		}
+	}
+
+	if builder.Validate {
+		builder.HasValidateMethod = hasValidateMethod(named)
+		builder.ErrorsPkg = f.importName("errors", "errors")
+
+		checks, err := f.collectChecks(builder.StructName, structType)
+		if err != nil {
+			return err
+		}
+		builder.Checks = checks
+	}
+
+	// Identifiers are allocated once the imports of the struct are
+	// known, so that none of them shadows an import
+	builder.Idents = f.idents(named.TypeParams())
+
+	if builder.GenerateBuilder {
+		builder.markRequired()
+
+		// Builders from existing instances copy them, which vet reports for
+		// locks
+		builder.GenerateFrom = !builder.copiesLock(structType)
+		if !builder.GenerateFrom {
+			reportWarning(pos, "skipping %sFrom and %s.%s: copying the struct would copy a lock", builder.NewBuilderName(), builder.StructName, builder.ToBuilderName())
+		}
+
+		// A field or method of the same name leaves no room for ToBuilder
+		member, _, _ := types.LookupFieldOrMethod(named, true, named.Obj().Pkg(), builder.ToBuilderName())
+		builder.GenerateToBuilder = builder.GenerateFrom && member == nil
+		if builder.GenerateFrom && member != nil {
+			reportWarning(pos, "skipping %s.%s: the name is already declared", builder.StructName, builder.ToBuilderName())
+		}
+	}
+
+	if builder.GenerateClone {
+		if member, _, _ := types.LookupFieldOrMethod(named, true, named.Obj().Pkg(), "Clone"); member != nil {
+			return fmt.Errorf("%s.Clone is already declared", builder.StructName)
+		}
+
+		// Clone starts from a shallow copy, which vet reports for locks
+		if hasLock(named) {
+			reportWarning(pos, "skipping %s.Clone: the struct holds a lock, which must not be copied", builder.StructName)
+			builder.GenerateClone = false
+		}
+	}
+
+	return nil
+}
+
+// claimOptionNames reserves the package-level functions generated for the
+// functional options of the builder, failing when another struct or a
+// declaration of the package already uses one of them.
+func (f *FolderData) claimOptionNames(builder BuilderData) error {
+	names := []string{"New" + builder.BuilderName, builder.StructName + "Option"}
+	for _, field := range builder.Fields {
+		names = append(names, field.OptionName())
+	}
+
+	for _, constructor := range builder.Constructors {
+		if constructor.Name == "New"+builder.BuilderName {
+			return fmt.Errorf("options constructor New%s conflicts with the generated constructor, name the constructor explicitly", builder.BuilderName)
+		}
+	}
+
+	for _, name := range names {
+		if owner, exists := f.OptionNames[name]; exists {
+			return fmt.Errorf("option %s is already generated for %s", name, owner)
+		}
+		if f.Types.Scope().Lookup(name) != nil {
+			return fmt.Errorf("option %s conflicts with a declaration of package %s", name, f.PackageName)
+		}
+	}
+
+	for _, name := range names {
+		f.OptionNames[name] = builder.StructName
+	}
+
+	return nil
+}
+
+// collectFields fills in the fields of the builder from the struct type,
+// along with everything derived from their gobok tags.
+func (f *FolderData) collectFields(fset *token.FileSet, builder *BuilderData, structType *types.Struct) error {
+	builder.fieldTypes = make(map[string]types.Type)
+	for i := 0; i < structType.NumFields(); i++ {
+		field := structType.Field(i)
+		tag := structType.Tag(i)
+		if invalidType(field.Type()) {
+			return f.invalidFieldError(fset, field)
+		}
+		if !includeField(field, tag) {
 			continue
 		}
 
-		builder := BuilderData{}
+		_, required := tagOption(tag, "required")
+		data := FieldData{
+			Name:       field.Name(),
+			SetterName: builder.SetterPrefix + capitalizeFirst(field.Name()),
+			Type:       f.typeString(field.Type()),
+			Required:   required,
+		}
 
-		for _, comment := range genDecl.Doc.List {
-			text := strings.TrimSpace(comment.Text)
+		f.convenienceTypes(&data, field.Type())
 
-			switch {
-			case text == "//gobok:builder":
-				builder.GenerateBuilder = true
-				folders[folder].HasBuilders = true
-			case text == "//gobok:constructor":
-				builder.GenerateConstructor = true
-				folders[folder].HasBuilders = true
-			case strings.HasPrefix(text, "//gobok:constructor:name="):
-				builder.GenerateConstructor = true
-				builder.ConstructorName = strings.TrimPrefix(text, "//gobok:constructor:name=")
-				folders[folder].HasBuilders = true
+		if value, ok := tagOption(tag, "default"); ok {
+			defaultValue, err := f.defaultValue(fset, field, value)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name(), err)
 			}
+			data.Default = defaultValue
 		}
 
-		if !builder.GenerateBuilder && !builder.GenerateConstructor {
+		builder.Fields = append(builder.Fields, data)
+		builder.fieldTypes[data.Name] = field.Type()
+	}
+
+	if builder.Flatten {
+		builder.Promoted = f.promotedFields(structType, builder)
+	}
+
+	builder.dropConvenienceCollisions()
+
+	return nil
+}
+
+// collectChecks returns the checks a validating builder or constructor makes
+// from the gobok tags of the struct fields.
+func (f *FolderData) collectChecks(structName string, structType *types.Struct) ([]CheckData, error) {
+	var checks []CheckData
+	for i := 0; i < structType.NumFields(); i++ {
+		field := structType.Field(i)
+		tag := structType.Tag(i)
+		if !includeField(field, tag) {
 			continue
 		}
 
-		typeSpec, ok := genDecl.Specs[0].(*ast.TypeSpec)
-		if !ok {
+		fieldChecks, err := f.validationChecks(structName, field, tag)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, fieldChecks...)
+	}
+
+	return checks, nil
+}
+
+// convenienceTypes records the element types of slice, map and pointer
+// fields, which get Add, Put and Value setters respectively. Value setters
+// take the pointee by value, so pointers to locks get none.
+func (f *FolderData) convenienceTypes(data *FieldData, t types.Type) {
+	if _, ok := t.(*types.TypeParam); ok {
+		return
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		data.Elem = f.typeString(u.Elem())
+	case *types.Map:
+		data.Key = f.typeString(u.Key())
+		data.Value = f.typeString(u.Elem())
+	case *types.Pointer:
+		if !hasLock(u.Elem()) {
+			data.Pointee = f.typeString(u.Elem())
+		}
+	}
+}
+
+// dropConvenienceCollisions removes the convenience setters whose name is
+// already used by another method of the builder.
+func (b *BuilderData) dropConvenienceCollisions() {
+	methods := make(map[string]int)
+	for name := range b.reserved {
+		methods[name]++
+	}
+	for _, field := range append(b.Fields, b.Promoted...) {
+		methods[field.SetterName]++
+		if field.Elem != "" {
+			methods[field.AddName()]++
+		}
+		if field.Key != "" {
+			methods[field.PutName()]++
+		}
+		if field.Pointee != "" {
+			methods[field.ValueName()]++
+		}
+	}
+
+	for i := range b.Fields {
+		field := &b.Fields[i]
+		if methods[field.AddName()] > 1 {
+			field.Elem = ""
+		}
+		if methods[field.PutName()] > 1 {
+			field.Key, field.Value = "", ""
+		}
+		if methods[field.ValueName()] > 1 {
+			field.Pointee = ""
+		}
+	}
+}
+
+// includeField reports whether a field takes part in generated code.
+// Unexported fields are private state and are left out unless tagged
+// `gobok:"include"`, while fields tagged `gobok:"-"` are always left out.
+func includeField(field *types.Var, tag string) bool {
+	if _, skip := tagOption(tag, "-"); skip {
+		return false
+	}
+	_, include := tagOption(tag, "include")
+	return field.Exported() || include
+}
+
+// promotedFields returns setters for the fields promoted from embedded
+// structs declared in the same package. Fields shadowed by a field of the
+// outer struct, or promoted from more than one embedded struct, are left out
+// just like Go leaves them out of the selector set.
+func (f *FolderData) promotedFields(structType *types.Struct, builder *BuilderData) []FieldData {
+	taken := make(map[string]bool)
+	for _, field := range builder.Fields {
+		taken[field.Name] = true
+		taken[field.SetterName] = true
+	}
+
+	var promoted []FieldData
+	seen := make(map[string]int)
+	for i := 0; i < structType.NumFields(); i++ {
+		embedded := structType.Field(i)
+		if !embedded.Embedded() {
 			continue
 		}
 
-		structType, ok := typeSpec.Type.(*ast.StructType)
+		embeddedType := embedded.Type()
+		pointer, isPointer := embeddedType.(*types.Pointer)
+		if isPointer {
+			embeddedType = pointer.Elem()
+		}
+
+		named, ok := embeddedType.(*types.Named)
+		if !ok || named.Obj().Pkg() != f.Types {
+			continue
+		}
+		inner, ok := named.Underlying().(*types.Struct)
 		if !ok {
 			continue
 		}
 
-		builder.StructName = typeSpec.Name.Name
-		builder.BuilderName = capitalizeFirst(builder.StructName)
-
-		for _, field := range structType.Fields.List {
-			for _, name := range field.Names {
-				fieldType := exprToString(field.Type)
-				builder.Fields = append(builder.Fields, FieldData{
-					Name:       name.Name,
-					SetterName: capitalizeFirst(name.Name),
-					Type:       fieldType,
-				})
-
-				// Track imports from field types
-				if strings.Contains(fieldType, ".") {
-					parts := strings.Split(fieldType, ".")
-					if len(parts) > 1 {
-						pkg := parts[0]
-						// Handle pointer types
-						if strings.HasPrefix(pkg, "*") {
-							pkg = pkg[1:]
-						}
-						// Handle array types
-						if strings.HasPrefix(pkg, "[]") {
-							pkg = pkg[2:]
-						}
-						// Handle map types
-						if strings.HasPrefix(pkg, "map[") {
-							pkg = strings.TrimPrefix(pkg, "map[")
-							pkg = strings.Split(pkg, "]")[0]
-						}
-						// Add the import if it's not a built-in type
-						if !isBuiltInType(pkg) {
-							// Try to find the import in the original imports
-							if importPath, exists := originalImports[pkg]; exists {
-								folders[folder].Imports[pkg] = importPath
-							}
-						}
-					}
-				}
+		for j := 0; j < inner.NumFields(); j++ {
+			field := inner.Field(j)
+			if !includeField(field, inner.Tag(j)) {
+				continue
+			}
+
+			data := FieldData{
+				Name:       field.Name(),
+				SetterName: builder.SetterPrefix + capitalizeFirst(field.Name()),
+				Type:       f.typeString(field.Type()),
+				Embedded:   embedded.Name(),
+			}
+			if isPointer {
+				data.EmbeddedType = f.typeString(embeddedType)
+			}
+			seen[data.SetterName]++
+			promoted = append(promoted, data)
+		}
+	}
+
+	var setters []FieldData
+	for _, field := range promoted {
+		if !taken[field.Name] && !taken[field.SetterName] && !builder.reserved[field.SetterName] && seen[field.SetterName] == 1 {
+			setters = append(setters, field)
+		}
+	}
+
+	return setters
+}
+
+// typeString renders t as it must be spelled inside the generated file,
+// registering an import for every package the type refers to.
+func (f *FolderData) typeString(t types.Type) string {
+	return types.TypeString(t, f.qualifier)
+}
+
+// qualifier implements types.Qualifier. Packages are referred to by their
+// name unless it is already taken by another import or by a package-level
+// declaration, in which case a numeric suffix is added.
+func (f *FolderData) qualifier(pkg *types.Package) string {
+	if pkg == f.Types {
+		return ""
+	}
+
+	if imp, exists := f.Imports[pkg.Path()]; exists {
+		if imp.Alias != "" {
+			return imp.Alias
+		}
+		return pkg.Name()
+	}
+
+	alias := pkg.Name()
+	for n := 2; f.nameTaken(alias); n++ {
+		alias = fmt.Sprintf("%s%d", pkg.Name(), n)
+	}
+
+	imp := ImportData{Path: pkg.Path()}
+	// Only spell out the alias when it differs from what the path implies
+	if alias != pkg.Name() || alias != path.Base(pkg.Path()) {
+		imp.Alias = alias
+	}
+	f.Imports[pkg.Path()] = imp
+
+	return alias
+}
+
+// importName registers an import the generated code needs regardless of the
+// field types and returns the name it is referred to by.
+func (f *FolderData) importName(path string, name string) string {
+	return f.qualifier(types.NewPackage(path, name))
+}
+
+// nameTaken reports whether name can not be used as an import alias.
+func (f *FolderData) nameTaken(name string) bool {
+	for importPath, imp := range f.Imports {
+		alias := imp.Alias
+		if alias == "" {
+			alias = path.Base(importPath)
+		}
+		if alias == name {
+			return true
+		}
+	}
+	return f.Types != nil && f.Types.Scope().Lookup(name) != nil
+}
+
+// typeParamsToString renders a generic type's parameter list twice: once with
+// constraints for declarations and once with bare names for instantiations.
+func (f *FolderData) typeParamsToString(list *types.TypeParamList) (params string, args string) {
+	if list.Len() == 0 {
+		return "", ""
+	}
+
+	var decls, names []string
+	for i := 0; i < list.Len(); i++ {
+		param := list.At(i)
+		names = append(names, param.Obj().Name())
+		decls = append(decls, param.Obj().Name()+" "+f.typeString(param.Constraint()))
+	}
+
+	return "[" + strings.Join(decls, ", ") + "]", "[" + strings.Join(names, ", ") + "]"
+}
+
+// outputFile is the formatted source of one generated file.
+type outputFile struct {
+	path   string
+	source []byte
+}
+
+// generateOutputs generates and formats the files of a folder without
+// writing them, reporting the ones that fail.
+func generateOutputs(folder string, data *FolderData) []outputFile {
+	// Only generate if there are builders in this directory
+	if !data.HasBuilders {
+		return nil
+	}
+
+	var files []outputFile
+	names, outputs := data.outputs()
+	for _, name := range names {
+		outPath := filepath.Join(folder, name)
+		source, err := generateSource(data, outputs[name])
+		if err != nil {
+			reportError(token.Position{Filename: outPath}, "%v", err)
+			continue
+		}
+
+		if !overwritable(outPath) {
+			reportError(token.Position{Filename: outPath}, "refusing to overwrite a file not generated by gobok")
+			continue
+		}
+
+		files = append(files, outputFile{path: outPath, source: source})
+	}
+	return files
+}
+
+// writeOutputs writes generated files to disk, or only lists them in a dry
+// run.
+func writeOutputs(files []outputFile) {
+	for _, file := range files {
+		if dryRun {
+			logf("Would write file: %s", file.path)
+			continue
+		}
+
+		logf("Generating file: %s", file.path)
+		if err := os.WriteFile(file.path, file.source, 0644); err != nil {
+			reportError(token.Position{Filename: file.path}, "failed to write file: %v", err)
+		}
+	}
+}
+
+// printBuilders writes the code generated for a folder to w, one output file
+// after the other. When there are several, each one is preceded by a
+// comment line holding its name.
+func printBuilders(w io.Writer, folder string, data *FolderData) {
+	names, outputs := data.outputs()
+	for i, name := range names {
+		outPath := filepath.Join(folder, name)
+		source, err := generateSource(data, outputs[name])
+		if err != nil {
+			reportError(token.Position{Filename: outPath}, "%v", err)
+			continue
+		}
+
+		if len(names) > 1 {
+			separator := "// " + name + "\n"
+			if i > 0 {
+				separator = "\n" + separator
 			}
+			source = append([]byte(separator), source...)
 		}
 
-		folders[folder].Builders = append(folders[folder].Builders, builder)
-	}
-}
-
-// Helper function to check if a type is a built-in Go type
-func isBuiltInType(typeName string) bool {
-	builtInTypes := map[string]bool{
-		"bool":       true,
-		"string":     true,
-		"int":        true,
-		"int8":       true,
-		"int16":      true,
-		"int32":      true,
-		"int64":      true,
-		"uint":       true,
-		"uint8":      true,
-		"uint16":     true,
-		"uint32":     true,
-		"uint64":     true,
-		"uintptr":    true,
-		"byte":       true,
-		"rune":       true,
-		"float32":    true,
-		"float64":    true,
-		"complex64":  true,
-		"complex128": true,
-		"error":      true,
-		"interface":  true,
-	}
-	return builtInTypes[typeName]
-}
-
-func writeBuilders(folder string, data *FolderData) {
-	// Only write if there are builders in this directory
+		if _, err := w.Write(source); err != nil {
+			reportError(token.Position{}, "failed to print %s: %v", outPath, err)
+			return
+		}
+	}
+}
+
+// checkBuilders compares the code generated for a folder with the files on
+// disk and prints a unified diff for each one that differs. It reports
+// whether all of them are up to date.
+func checkBuilders(folder string, data *FolderData) bool {
 	if !data.HasBuilders {
-		return
+		return true
 	}
 
+	upToDate := true
+	names, outputs := data.outputs()
+	for _, name := range names {
+		outPath := filepath.Join(folder, name)
+		source, err := generateSource(data, outputs[name])
+		if err != nil {
+			reportError(token.Position{Filename: outPath}, "%v", err)
+			upToDate = false
+			continue
+		}
+
+		if !overwritable(outPath) {
+			reportError(token.Position{Filename: outPath}, "output collides with a file not generated by gobok")
+			upToDate = false
+			continue
+		}
+
+		current, err := os.ReadFile(outPath)
+		if err != nil && !os.IsNotExist(err) {
+			reportError(token.Position{Filename: outPath}, "failed to read file: %v", err)
+			upToDate = false
+			continue
+		}
+
+		if bytes.Equal(current, source) {
+			continue
+		}
+
+		logf("Stale file: %s", outPath)
+		fmt.Print(unifiedDiff(outPath, outPath+" (generated)", current, source))
+		upToDate = false
+	}
+
+	return upToDate
+}
+
+// outputs groups the builders of a folder by the file they are generated
+// into, returning the file names in the order they first appear.
+func (f *FolderData) outputs() ([]string, map[string][]BuilderData) {
+	var names []string
+	outputs := make(map[string][]BuilderData)
+	for _, builder := range f.Builders {
+		if outputs[builder.output] == nil {
+			names = append(names, builder.output)
+		}
+		outputs[builder.output] = append(outputs[builder.output], builder)
+	}
+	return names, outputs
+}
+
+// overwritable reports whether gobok may write to path: either nothing is
+// there yet or the file was generated by gobok itself.
+func overwritable(path string) bool {
+	_, err := os.Stat(path)
+	return os.IsNotExist(err) || isGenerated(path)
+}
+
+// generateSource renders and formats the code generated for some builders of
+// a folder. Code that can not be formatted is invalid Go and is never
+// returned.
+func generateSource(data *FolderData, builders []BuilderData) ([]byte, error) {
 	tmpl, err := template.New("builder").Parse(builderTemplate)
 	if err != nil {
-		fmt.Printf("Failed to parse template: %v\n", err)
-		return
+		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	// Convert imports map to slice of ImportData
+	// Convert imports map to a slice of ImportData sorted by path
 	imports := make([]ImportData, 0, len(data.Imports))
-	for alias, path := range data.Imports {
-		// Extract the last part of the path
-		parts := strings.Split(path, "/")
-		lastPart := parts[len(parts)-1]
-
-		// If the alias matches the last part of the path, we don't need an alias
-		if alias == lastPart {
-			imports = append(imports, ImportData{
-				Path: path,
-			})
-		} else {
-			imports = append(imports, ImportData{
-				Alias: alias,
-				Path:  path,
-			})
+	for _, imp := range data.Imports {
+		imports = append(imports, imp)
+	}
+	sort.Slice(imports, func(i, j int) bool {
+		return imports[i].Path < imports[j].Path
+	})
+
+	for _, builder := range builders[1:] {
+		if builder.constraint != builders[0].constraint {
+			return nil, fmt.Errorf("%s and %s have different build constraints and can not share a file", builders[0].StructName, builder.StructName)
 		}
 	}
 
 	outData := TemplateData{
 		PackageName: data.PackageName,
-		Builders:    data.Builders,
+		Constraint:  builders[0].constraint,
+		Builders:    builders,
 		ToolVersion: toolVersion,
 		Imports:     imports,
 	}
@@ -288,103 +1114,81 @@ func writeBuilders(folder string, data *FolderData) {
 	var buf strings.Builder
 	err = tmpl.Execute(&buf, outData)
 	if err != nil {
-		fmt.Printf("Failed to execute template: %v\n", err)
-		return
+		return nil, fmt.Errorf("failed to execute template: %w", err)
 	}
 
 	source, err := format.Source([]byte(buf.String()))
 	if err != nil {
-		fmt.Printf("Failed to format generated code: %v\n", err)
-		source = []byte(buf.String())
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
 	}
 
-	outPath := filepath.Join(folder, "gobok.go")
-	fmt.Printf("[gobok] Generating file: %s\n", outPath)
-	err = os.WriteFile(outPath, source, 0644)
+	source, err = pruneImports(source)
 	if err != nil {
-		fmt.Printf("Failed to write file %s: %v\n", outPath, err)
-	}
-}
-
-func exprToString(expr ast.Expr) string {
-	switch t := expr.(type) {
-	case *ast.Ident:
-		return t.Name
-	case *ast.StarExpr:
-		return "*" + exprToString(t.X)
-	case *ast.SelectorExpr:
-		return exprToString(t.X) + "." + t.Sel.Name
-	case *ast.ArrayType:
-		return "[]" + exprToString(t.Elt)
-	case *ast.MapType:
-		return "map[" + exprToString(t.Key) + "]" + exprToString(t.Value)
-	case *ast.ChanType:
-		dir := ""
-		if t.Dir == ast.SEND {
-			dir = "chan<- "
-		} else if t.Dir == ast.RECV {
-			dir = "<-chan "
-		} else {
-			dir = "chan "
-		}
-		return dir + exprToString(t.Value)
-	case *ast.FuncType:
-		var params []string
-		for _, f := range t.Params.List {
-			for range f.Names {
-				params = append(params, exprToString(f.Type))
-			}
+		return nil, fmt.Errorf("failed to prune imports: %w", err)
+	}
+
+	return source, nil
+}
+
+// markRequired switches the builder to staged mode when any field is
+// required. A builder declared staged without required fields treats every
+// field as required.
+func (b *BuilderData) markRequired() {
+	for _, field := range b.Fields {
+		if field.Required {
+			b.Staged = true
+			return
 		}
+	}
 
-		var results []string
-		if t.Results != nil {
-			for _, f := range t.Results.List {
-				for _ = range f.Names {
-					results = append(results, exprToString(f.Type))
-				}
-				if len(f.Names) == 0 {
-					results = append(results, exprToString(f.Type))
-				}
-			}
+	if b.Staged {
+		for i := range b.Fields {
+			b.Fields[i].Required = true
 		}
+	}
+}
 
-		paramList := strings.Join(params, ", ")
-		resultList := strings.Join(results, ", ")
+// tagOption looks up an option in the gobok key of a struct tag, such as
+// required in `gobok:"required"` or min in `gobok:"min=1"`. A default option
+// takes the remainder of the tag as its value so that it may contain commas,
+// which means it has to come last.
+func tagOption(tag string, name string) (value string, ok bool) {
+	options, found := reflect.StructTag(tag).Lookup("gobok")
+	if !found {
+		return "", false
+	}
 
-		if len(results) == 1 {
-			return fmt.Sprintf("func(%s) %s", paramList, resultList)
-		} else if len(results) > 1 {
-			return fmt.Sprintf("func(%s) (%s)", paramList, resultList)
+	for options != "" {
+		option, rest, _ := strings.Cut(options, ",")
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		if key == "default" {
+			_, value, _ = strings.Cut(options, "=")
+			rest = ""
 		}
-		return fmt.Sprintf("func(%s)", paramList)
-
-	case *ast.StructType:
-		var fields []string
-		for _, f := range t.Fields.List {
-			for _, name := range f.Names {
-				fields = append(fields, fmt.Sprintf("%s %s", name.Name, exprToString(f.Type)))
-			}
-			if len(f.Names) == 0 {
-				fields = append(fields, exprToString(f.Type))
-			}
+		if key == name {
+			return strings.TrimSpace(value), true
 		}
-		return fmt.Sprintf("struct { %s }", strings.Join(fields, "; "))
+		options = rest
+	}
 
-	case *ast.InterfaceType:
-		var methods []string
-		for _, f := range t.Methods.List {
-			for _, name := range f.Names {
-				methods = append(methods, fmt.Sprintf("%s %s", name.Name, exprToString(f.Type)))
-			}
-			if len(f.Names) == 0 {
-				methods = append(methods, exprToString(f.Type))
-			}
-		}
-		return fmt.Sprintf("interface { %s }", strings.Join(methods, "; "))
+	return "", false
+}
 
-	default:
-		return "interface{}"
+// lowerFirst turns an exported identifier into an unexported one, lowering a
+// leading initialism as a whole: User becomes user and URLConfig urlConfig.
+func lowerFirst(s string) string {
+	runes := []rune(s)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		// The last capital of an initialism starts the next word
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
 	}
+	return string(runes)
 }
 
 func capitalizeFirst(s string) string {
@@ -1,13 +1,40 @@
 package main
 
 import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
 	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+
+	"golang.org/x/tools/go/packages"
 )
 
+// processPackage collects the builder data of the given files of one
+// directory.
+func processPackage(dir string, files []string) {
+	processPackages([]string{dir}, map[string][]string{dir: files}, nil)
+}
+
+// writeBuilders writes the files generated for one directory, unless one of
+// them fails.
+func writeBuilders(dir string, data *FolderData) {
+	before := failures
+	outputs := generateOutputs(dir, data)
+	if failures == before {
+		writeOutputs(outputs)
+	}
+}
+
 func TestProcessFile(t *testing.T) {
 	// Create a temporary test file
 	tempDir := t.TempDir()
@@ -28,7 +55,7 @@ type TestStruct struct {
 	}
 
 	// Process the test file
-	processFile(testFile)
+	processPackage(tempDir, []string{testFile})
 
 	// Verify the folder data was created
 	folder := tempDir
@@ -52,9 +79,9 @@ type TestStruct struct {
 
 	// Verify fields
 	expectedFields := []FieldData{
-		{Name: "Name", Type: "string"},
-		{Name: "Age", Type: "int"},
-		{Name: "Tags", Type: "[]string"},
+		{Name: "Name", SetterName: "Name", Type: "string"},
+		{Name: "Age", SetterName: "Age", Type: "int"},
+		{Name: "Tags", SetterName: "Tags", Type: "[]string", Elem: "string"},
 	}
 
 	if len(builder.Fields) != len(expectedFields) {
@@ -68,7 +95,128 @@ type TestStruct struct {
 	}
 }
 
-func TestExprToString(t *testing.T) {
+func TestProcessFileGeneric(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+//gobok:builder
+//gobok:constructor
+type Result[T any, E interface{ ~int | ~string }] struct {
+	Value T
+	Err   E
+	Page  []Result[T, E]
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	if folders[tempDir] == nil || len(folders[tempDir].Builders) != 1 {
+		t.Fatal("Expected 1 builder to be collected")
+	}
+
+	builder := folders[tempDir].Builders[0]
+	if builder.TypeParams != "[T any, E interface{~int | ~string}]" {
+		t.Errorf("Unexpected type params: %q", builder.TypeParams)
+	}
+	if builder.TypeArgs != "[T, E]" {
+		t.Errorf("Unexpected type args: %q", builder.TypeArgs)
+	}
+	if builder.Fields[2].Type != "[]Result[T, E]" {
+		t.Errorf("Unexpected field type: %q", builder.Fields[2].Type)
+	}
+
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"type ResultBuilder[T any, E interface{ ~int | ~string }] struct",
+		"func NewResultBuilder[T any, E interface{ ~int | ~string }]() *ResultBuilder[T, E]",
+		"func (b *ResultBuilder[T, E]) Value(v T) *ResultBuilder[T, E]",
+		"func (b *ResultBuilder[T, E]) Build() *Result[T, E]",
+		"func NewResult[T any, E interface{ ~int | ~string }](value T, err E, page []Result[T, E]) Result[T, E]",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+}
+
+func TestProcessPackagesModule(t *testing.T) {
+	tempDir := t.TempDir()
+	modelsDir := filepath.Join(tempDir, "models")
+	appDir := filepath.Join(tempDir, "app")
+
+	files := map[string]string{
+		filepath.Join(tempDir, "go.mod"): "module example.com/m\n\ngo 1.22\n",
+		filepath.Join(modelsDir, "user.go"): `package models
+
+import "time"
+
+//gobok:builder
+type User struct {
+	Name    string
+	Created time.Time
+}
+
+var _ = NewUserBuilder()
+`,
+		// A stale generated file whose setter must not count as declared
+		// by hand
+		filepath.Join(modelsDir, "gobok.go"): generatedPrefix + ". DO NOT EDIT.\npackage models\n\nfunc (b *UserBuilder) Name(name int) *UserBuilder { return b }\n",
+		filepath.Join(appDir, "app.go"): `package app
+
+import "example.com/m/models"
+
+//gobok:builder
+type Account struct {
+	Owner models.User
+}
+`,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	before := failures
+	processPackages([]string{modelsDir, appDir},
+		map[string][]string{modelsDir: {filepath.Join(modelsDir, "user.go")}, appDir: {filepath.Join(appDir, "app.go")}},
+		map[string][]string{modelsDir: {filepath.Join(modelsDir, "gobok.go")}})
+
+	if failures != before {
+		t.Errorf("Expected the module to load without failures")
+	}
+	if folders[modelsDir] == nil || len(folders[modelsDir].Builders) != 1 {
+		t.Fatal("Expected 1 builder in models")
+	}
+	if got := folders[modelsDir].Builders[0].Fields[1].Type; got != "time.Time" {
+		t.Errorf("Expected the dependency type time.Time, got %q", got)
+	}
+	if folders[appDir] == nil || len(folders[appDir].Builders) != 1 {
+		t.Fatal("Expected 1 builder in app")
+	}
+	if got := folders[appDir].Builders[0].Fields[0].Type; got != "models.User" {
+		t.Errorf("Expected the field type models.User, got %q", got)
+	}
+}
+
+func TestTypeString(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
@@ -109,16 +257,107 @@ func TestExprToString(t *testing.T) {
 			input:    "<-chan int",
 			expected: "<-chan int",
 		},
+		{
+			name:     "generic instantiation",
+			input:    "Page[T]",
+			expected: "Page[T]",
+		},
+		{
+			name:     "generic instantiation with multiple arguments",
+			input:    "map[string]Result[T, int]",
+			expected: "map[string]Result[T, int]",
+		},
+		{
+			name:     "fixed-size array",
+			input:    "[32]byte",
+			expected: "[32]byte",
+		},
+		{
+			name:     "array length from a constant expression",
+			input:    "[size * 2][sha256.Size]byte",
+			expected: "[8][32]byte",
+		},
+		{
+			name:     "parenthesised type",
+			input:    "[](*int)",
+			expected: "[]*int",
+		},
+		{
+			name:     "func with named and variadic params",
+			input:    "func(name string, args ...interface{}) (n int, err error)",
+			expected: "func(name string, args ...interface{}) (n int, err error)",
+		},
+		{
+			name:     "func with unnamed variadic param",
+			input:    "func(string, ...time.Duration) error",
+			expected: "func(string, ...time.Duration) error",
+		},
+		{
+			name:     "generic instantiation with a qualified argument",
+			input:    "Result[time.Time, [4]int]",
+			expected: "Result[time.Time, [4]int]",
+		},
+		{
+			name:     "anonymous struct with tags",
+			input:    "struct { A int `json:\"a\"`; B *T }",
+			expected: "struct{A int \"json:\\\"a\\\"\"; B *T}",
+		},
+		{
+			name:     "qualified type nested in a map",
+			input:    "map[string]*time.Location",
+			expected: "map[string]*time.Location",
+		},
+		{
+			name:     "qualified type nested in a func",
+			input:    "func([]time.Duration) error",
+			expected: "func([]time.Duration) error",
+		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			expr, err := parser.ParseExpr(tt.input)
-			if err != nil {
-				t.Fatalf("Failed to parse expression: %v", err)
-			}
+	// All cases share one package since loading it is the expensive part
+	var fields strings.Builder
+	for i, tt := range tests {
+		fmt.Fprintf(&fields, "\tField%d %s\n", i, tt.input)
+	}
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import (
+	"crypto/sha256"
+	"time"
+)
+
+var _ time.Duration
+
+var _ = sha256.Size
+
+const size = 4
+
+type Page[T any] struct{}
+
+type Result[T any, E comparable] struct{}
+
+//gobok:builder
+type TestStruct[T any] struct {
+` + fields.String() + `}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	if folders[tempDir] == nil || len(folders[tempDir].Builders) != 1 {
+		t.Fatal("Expected 1 builder to be collected")
+	}
 
-			result := exprToString(expr)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := folders[tempDir].Builders[0].Fields[i].Type
 			if result != tt.expected {
 				t.Errorf("Expected %q, got %q", tt.expected, result)
 			}
@@ -126,6 +365,56 @@ func TestExprToString(t *testing.T) {
 	}
 }
 
+func TestImports(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import (
+	"html/template"
+	ttemplate "text/template"
+	. "time"
+)
+
+var template2 = 0
+
+//gobok:builder
+type TestStruct struct {
+	Text    map[string]*ttemplate.Template
+	HTML    []func() *template.Template
+	Timeout Duration
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		`"text/template"`,
+		`template3 "html/template"`,
+		`"time"`,
+		"func (b *TestStructBuilder) Text(v map[string]*template.Template) *TestStructBuilder",
+		"func (b *TestStructBuilder) HTML(v []func() *template3.Template) *TestStructBuilder",
+		"func (b *TestStructBuilder) Timeout(v time.Duration) *TestStructBuilder",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+}
+
 func TestWriteBuilders(t *testing.T) {
 	// Create a temporary test file
 	tempDir := t.TempDir()
@@ -145,7 +434,7 @@ type TestStruct struct {
 	}
 
 	// Process the test file
-	processFile(testFile)
+	processPackage(tempDir, []string{testFile})
 
 	// Write the builders
 	writeBuilders(tempDir, folders[tempDir])
@@ -174,3 +463,1929 @@ type TestStruct struct {
 		t.Error("Generated file does not contain Build method")
 	}
 }
+
+func TestWriteBuildersStaged(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+//gobok:builder
+type Account struct {
+	Email string ` + "`gobok:\"required\"`" + `
+	Name  string ` + "`json:\"name\" gobok:\"required\"`" + `
+	Age   int
+}
+
+//gobok:builder:staged
+type Point struct {
+	X int
+	Y int
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	builders := folders[tempDir].Builders
+	if len(builders) != 2 || !builders[0].Staged || !builders[1].Staged {
+		t.Fatal("Expected 2 staged builders")
+	}
+	if !builders[0].Fields[0].Required || !builders[0].Fields[1].Required || builders[0].Fields[2].Required {
+		t.Errorf("Unexpected required fields: %v", builders[0].Fields)
+	}
+
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"type AccountEmailStep interface {\n\tEmail(v string) AccountNameStep\n}",
+		"type AccountNameStep interface {\n\tName(v string) *AccountBuilder\n}",
+		"func NewAccountBuilder() AccountEmailStep",
+		"func (b *stagedAccountBuilder) Name(v string) *AccountBuilder",
+		"func (b *AccountBuilder) Age(v int) *AccountBuilder",
+		"func (b *AccountBuilder) Build() *Account",
+		"type PointXStep interface {\n\tX(v int) PointYStep\n}",
+		"func NewPointBuilder() PointXStep",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+	if strings.Contains(contentStr, "func (b *AccountBuilder) Email(") {
+		t.Error("Required field must not have a setter on the builder")
+	}
+}
+
+func TestWriteBuildersValidate(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import "errors"
+
+type Window struct{ Start, End int }
+
+//gobok:builder:validate
+type Server struct {
+	Host   string ` + "`gobok:\"required,max=64\"`" + `
+	Port   uint16 ` + "`gobok:\"min=1\"`" + `
+	Tags   []string ` + "`gobok:\"nonzero\"`" + `
+	Window Window ` + "`gobok:\"nonzero\"`" + `
+	Ratio  float64 ` + "`gobok:\"max=0.5\"`" + `
+}
+
+func (s *Server) Validate() error {
+	return errors.New("invalid")
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	builder := folders[tempDir].Builders[0]
+	if !builder.Validate || !builder.HasValidateMethod {
+		t.Fatal("Expected a validating builder that calls Validate")
+	}
+
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		`"errors"`,
+		`"reflect"`,
+		"func (b *ServerBuilder) Build() (*Server, error)",
+		`if b.instance.Host == "" {`,
+		`errs = append(errs, errors.New("Server.Host must be set"))`,
+		"if len(b.instance.Host) > 64 {",
+		`errors.New("Server.Host must be of length at most 64")`,
+		"if b.instance.Port < 1 {",
+		"if b.instance.Tags == nil {",
+		"if reflect.ValueOf(&b.instance.Window).Elem().IsZero() {",
+		"if b.instance.Ratio > 0.5 {",
+		"if err := b.instance.Validate(); err != nil {",
+		"if err := errors.Join(errs...); err != nil {",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+}
+
+func TestValidationChecksInvalidRule(t *testing.T) {
+	tests := []struct {
+		name string
+		kind types.BasicKind
+		tag  string
+	}{
+		{name: "not a number", kind: types.Int, tag: `gobok:"min=one"`},
+		{name: "infinity", kind: types.Float64, tag: `gobok:"max=Inf"`},
+		{name: "not a number float", kind: types.Float64, tag: `gobok:"max=NaN"`},
+		{name: "overflow", kind: types.Int8, tag: `gobok:"max=1000"`},
+		{name: "negative unsigned", kind: types.Uint, tag: `gobok:"min=-1"`},
+		{name: "fraction of an integer", kind: types.Int, tag: `gobok:"min=1.5"`},
+		{name: "expression", kind: types.Int, tag: `gobok:"min=len(x)"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folder := &FolderData{Imports: make(map[string]ImportData)}
+			field := types.NewField(token.NoPos, nil, "Port", types.Typ[tt.kind], false)
+
+			_, err := folder.validationChecks("Server", field, tt.tag)
+			if err == nil {
+				t.Errorf("Expected an error for the bound %s", tt.tag)
+			}
+		})
+	}
+}
+
+func TestWriteBuildersDefaults(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import clock "time"
+
+const defaultHost = "localhost"
+
+//gobok:builder
+type Config struct {
+	Host    string ` + "`gobok:\"default=defaultHost\"`" + `
+	Port    uint16 ` + "`gobok:\"default=8080\"`" + `
+	Timeout clock.Duration ` + "`gobok:\"default=clock.Second*30\"`" + `
+	Tags    []string ` + "`gobok:\"default=[]string{\\\"a\\\", \\\"b\\\"}\"`" + `
+	Debug   bool
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		`"time"`,
+		"Host:    defaultHost,",
+		"Port:    8080,",
+		"Timeout: time.Second * 30,",
+		`Tags:    []string{"a", "b"},`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+}
+
+func TestProcessPackageInvalidDefaults(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+	}{
+		{name: "overflow", field: "Port uint8 `gobok:\"default=256\"`"},
+		{name: "truncation", field: "Port int `gobok:\"default=1.5\"`"},
+		{name: "type mismatch", field: "Port int `gobok:\"default=\\\"80\\\"\"`"},
+		{name: "undefined identifier", field: "Port int `gobok:\"default=missing\"`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "test.go")
+
+			content := "package test\n\n//gobok:builder\ntype Config struct {\n\t" + tt.field + "\n}\n"
+
+			err := os.WriteFile(testFile, []byte(content), 0644)
+			if err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			before := failures
+			processPackage(tempDir, []string{testFile})
+
+			if len(folders[tempDir].Builders) != 0 {
+				t.Errorf("Expected the struct to be rejected, got %v", folders[tempDir].Builders)
+			}
+			if failures != before+1 {
+				t.Errorf("Expected the rejected struct to be reported as a failure")
+			}
+		})
+	}
+}
+
+func TestProcessPackageInvalidFieldTypes(t *testing.T) {
+	tests := []struct {
+		name      string
+		directive string
+		field     string
+	}{
+		{name: "undefined type", directive: "builder", field: "Address Missing"},
+		{name: "slice of undefined type", directive: "builder", field: "Tags []Missing"},
+		{name: "map of pointers", directive: "constructor", field: "Index map[string]*Missing"},
+		{name: "generic argument", directive: "options", field: "Page Page[Missing]"},
+		{name: "excluded field", directive: "clone", field: "cache []Missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "test.go")
+
+			content := "package test\n\ntype Page[T any] struct{ Items []T }\n\n//gobok:" + tt.directive + "\ntype Config struct {\n\tName string\n\t" + tt.field + "\n}\n"
+
+			err := os.WriteFile(testFile, []byte(content), 0644)
+			if err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			stderr := os.Stderr
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("Failed to create pipe: %v", err)
+			}
+			os.Stderr = w
+			before := failures
+			processPackage(tempDir, []string{testFile})
+			os.Stderr = stderr
+			w.Close()
+			output, _ := io.ReadAll(r)
+
+			if len(folders[tempDir].Builders) != 0 {
+				t.Errorf("Expected the struct to be rejected, got %v", folders[tempDir].Builders)
+			}
+			if failures != before+1 {
+				t.Errorf("Expected the rejected struct to be reported as a failure")
+			}
+			if want := testFile + ":8:"; !strings.Contains(string(output), want) || !strings.Contains(string(output), "undefined: Missing") {
+				t.Errorf("Expected the type error at %s, got %s", want, output)
+			}
+		})
+	}
+}
+
+func TestWriteBuildersOptions(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+//gobok:options
+type Client[T any] struct {
+	BaseURL string ` + "`gobok:\"default=\\\"http://localhost\\\"\"`" + `
+	Retries int
+	Codec   T
+}
+
+//gobok:options
+type Server struct {
+	Retries int
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	if len(folders[tempDir].Builders) != 1 {
+		t.Fatalf("Expected the conflicting WithRetries option to be rejected, got %d builders", len(folders[tempDir].Builders))
+	}
+
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"type ClientOption[T any] func(*Client[T])",
+		"func WithBaseURL[T any](v string) ClientOption[T]",
+		"func WithCodec[T any](v T) ClientOption[T]",
+		"func NewClient[T any](opts ...ClientOption[T]) *Client[T]",
+		`BaseURL: "http://localhost",`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+	if strings.Contains(contentStr, "ClientBuilder") {
+		t.Error("Options must not generate a builder")
+	}
+}
+
+func TestWriteBuildersImmutable(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+//gobok:builder:immutable
+type Request struct {
+	Host string ` + "`gobok:\"required\"`" + `
+	Path string
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"type RequestBuilder struct {\n\tinstance Request\n}",
+		"type RequestHostStep interface {\n\tHost(v string) RequestBuilder\n}",
+		"func (b stagedRequestBuilder) Host(v string) RequestBuilder",
+		"return RequestBuilder{instance: b.instance}",
+		"func (b RequestBuilder) Path(v string) RequestBuilder",
+		"func (b RequestBuilder) Build() *Request {\n\tinstance := b.instance\n\treturn &instance\n}",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+}
+
+func TestWriteBuildersClone(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+//gobok:clone
+type Node[T any] struct {
+	Value    T
+	Children []*Node[T]
+	Weights  map[string][]float64
+	Ratio    *float64
+	Grid     [2][]int
+	Name     string
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"func (s *Node[T]) Clone() *Node[T] {",
+		"c := *s",
+		"c.Children = make([]*Node[T], len(s.Children))",
+		"c.Children[i1] = v1.Clone()",
+		"c.Weights = make(map[string][]float64, len(s.Weights))",
+		"c1 = make([]float64, len(v1))",
+		"v1 := *s.Ratio",
+		"c.Ratio = &v1",
+		"for i1, v1 := range s.Grid {",
+		"c.Grid[i1] = make([]int, len(v1))",
+		"return &c",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+	if strings.Contains(contentStr, "c.Name") || strings.Contains(contentStr, "c.Value") {
+		t.Error("Value fields must be left to the shallow copy")
+	}
+}
+
+func TestWriteBuildersCloneLocks(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import "sync"
+
+//gobok:clone
+type Cache struct {
+	Entries map[string]string
+	mu      sync.Mutex
+}
+
+//gobok:clone
+type Worker struct {
+	Jobs   []string
+	Mu     *sync.Mutex
+	Guards map[string]sync.Mutex
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedFile := filepath.Join(tempDir, "gobok.go")
+	generatedContent, err := os.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	if !strings.Contains(contentStr, "func (s *Worker) Clone() *Worker {") {
+		t.Errorf("Generated file does not contain the Clone method of Worker\n%s", contentStr)
+	}
+	if strings.Contains(contentStr, "func (s *Cache) Clone()") {
+		t.Errorf("Expected no Clone method copying a struct that holds a lock\n%s", contentStr)
+	}
+	if strings.Contains(contentStr, "c.Mu") || strings.Contains(contentStr, "c.Guards") {
+		t.Errorf("Expected locks to be left to the shallow copy\n%s", contentStr)
+	}
+
+	cfg := &packages.Config{Mode: packages.NeedTypes | packages.NeedDeps | packages.NeedImports, Dir: tempDir}
+	pkgs, err := packages.Load(cfg, testFile, generatedFile)
+	if err != nil {
+		t.Fatalf("Failed to load generated code: %v", err)
+	}
+	for _, pkgErr := range pkgs[0].Errors {
+		t.Errorf("Generated code does not compile: %v", pkgErr)
+	}
+}
+
+func TestProcessPackageCloneDeclared(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+//gobok:clone
+type Config struct {
+	Hosts []string
+}
+
+func (c *Config) Clone() *Config {
+	return c
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	before := failures
+	processPackage(tempDir, []string{testFile})
+	if failures != before+1 {
+		t.Errorf("Expected a Clone method declared by hand to fail, got %d failures", failures-before)
+	}
+}
+
+func TestProcessFileEmbedded(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import "time"
+
+type Person struct {
+	Name string
+	Age  int
+}
+
+type Audit struct {
+	Name    string
+	Created int64
+}
+
+//gobok:builder:flatten
+type Admin struct {
+	Person
+	*Audit
+	time.Location
+	Level int
+	age   int ` + "`gobok:\"include\"`" + `
+	id    int
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	builder := folders[tempDir].Builders[0]
+
+	expectedFields := []FieldData{
+		{Name: "Person", SetterName: "Person", Type: "Person"},
+		{Name: "Audit", SetterName: "Audit", Type: "*Audit", Pointee: "Audit"},
+		{Name: "Location", SetterName: "Location", Type: "time.Location"},
+		{Name: "Level", SetterName: "Level", Type: "int"},
+		{Name: "age", SetterName: "Age", Type: "int"},
+	}
+	if len(builder.Fields) != len(expectedFields) {
+		t.Fatalf("Expected %d fields, got %d", len(expectedFields), len(builder.Fields))
+	}
+	for i, field := range builder.Fields {
+		if field != expectedFields[i] {
+			t.Errorf("Field %d mismatch: expected %v, got %v", i, expectedFields[i], field)
+		}
+	}
+
+	// Name is ambiguous, Age is shadowed by the age setter and time.Location
+	// is declared in another package
+	expectedPromoted := []FieldData{
+		{Name: "Created", SetterName: "Created", Type: "int64", Embedded: "Audit", EmbeddedType: "Audit"},
+	}
+	if len(builder.Promoted) != len(expectedPromoted) {
+		t.Fatalf("Expected %d promoted fields, got %v", len(expectedPromoted), builder.Promoted)
+	}
+	for i, field := range builder.Promoted {
+		if field != expectedPromoted[i] {
+			t.Errorf("Promoted field %d mismatch: expected %v, got %v", i, expectedPromoted[i], field)
+		}
+	}
+}
+
+func TestWriteBuildersPrivate(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+//gobok:builder:private
+type Session struct {
+	Token string ` + "`gobok:\"required\"`" + `
+	id    int ` + "`gobok:\"include\"`" + `
+	cache map[string]string
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"type sessionBuilder struct",
+		"type sessionTokenStep interface {\n\tToken(v string) *sessionBuilder\n}",
+		"func newSessionBuilder() sessionTokenStep",
+		"func (b *sessionBuilder) Id(v int) *sessionBuilder",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+	if strings.Contains(contentStr, "cache") {
+		t.Error("Unexported fields must be skipped unless included")
+	}
+}
+
+func TestLowerFirst(t *testing.T) {
+	tests := map[string]string{
+		"User":      "user",
+		"URLConfig": "urlConfig",
+		"URL":       "url",
+		"ID":        "id",
+		"user":      "user",
+		"A":         "a",
+	}
+
+	for input, expected := range tests {
+		if result := lowerFirst(input); result != expected {
+			t.Errorf("lowerFirst(%q): expected %q, got %q", input, expected, result)
+		}
+	}
+}
+
+func TestWriteBuildersConvenienceSetters(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import "sync"
+
+type Labels map[string]string
+
+//gobok:builder
+type Contact struct {
+	Tags     []string
+	Metadata map[string]interface{}
+	Labels   Labels
+	Phone    *string
+	Nickname *string
+	PhoneValue int
+	Mu       *sync.Mutex
+	Pending  *sync.WaitGroup
+}
+
+//gobok:builder:immutable
+type Request struct {
+	Headers map[string][]string
+	Path    []string
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"func (b *ContactBuilder) AddTags(v ...string) *ContactBuilder {\n\tb.instance.Tags = append(b.instance.Tags, v...)",
+		"func (b *ContactBuilder) PutMetadata(k string, v interface{}) *ContactBuilder {\n\tif b.instance.Metadata == nil {\n\t\tb.instance.Metadata = make(map[string]interface{})",
+		"func (b *ContactBuilder) PutLabels(k string, v string) *ContactBuilder {\n\tif b.instance.Labels == nil {\n\t\tb.instance.Labels = make(Labels)",
+		"func (b *ContactBuilder) NicknameValue(v string) *ContactBuilder {\n\tb.instance.Nickname = &v",
+		"b.instance.Path = append(b.instance.Path[:len(b.instance.Path):len(b.instance.Path)], v...)",
+		"m := make(map[string][]string, len(b.instance.Headers)+1)",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+	if strings.Count(contentStr, ") PhoneValue(") != 1 || !strings.Contains(contentStr, "PhoneValue(v int)") {
+		t.Error("Convenience setters must not collide with field setters")
+	}
+	if strings.Contains(contentStr, "MuValue") || strings.Contains(contentStr, "PendingValue") {
+		t.Errorf("Expected no Value setters copying a lock\n%s", contentStr)
+	}
+}
+
+func TestWriteBuildersNested(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import "sync"
+
+//gobok:builder
+type Contact struct {
+	Address  *Address
+	Billing  Address
+	Page     Page[int]
+	Password Secret
+	Guard    *Guard
+	// Takes the name of the Value setter of Address
+	AddressValue string
+}
+
+//gobok:builder
+type Address struct {
+	City string
+}
+
+//gobok:builder
+type Page[T any] struct {
+	Items []T
+}
+
+//gobok:builder:validate
+type Secret struct {
+	Value string ` + "`gobok:\"required\"`" + `
+}
+
+//gobok:builder
+type Guard struct {
+	mu sync.Mutex
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"func (b *ContactBuilder) AddressWith(fn func(*AddressBuilder)) *ContactBuilder {\n\tnested := NewAddressBuilderFrom(b.instance.Address)\n\tfn(nested)\n\tb.instance.Address = nested.instance",
+		"func (b *ContactBuilder) BillingWith(fn func(*AddressBuilder)) *ContactBuilder {\n\tfn(&AddressBuilder{instance: &b.instance.Billing})",
+		"func (b *ContactBuilder) PageWith(fn func(*PageBuilder[int])) *ContactBuilder",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+	if strings.Contains(contentStr, "PasswordWith") {
+		t.Error("Validating builders must not be driven in place")
+	}
+	if strings.Contains(contentStr, "GuardWith") {
+		t.Errorf("Expected no nested setter copying a lock\n%s", contentStr)
+	}
+}
+
+func TestWriteBuildersFromInstance(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import "sync"
+
+//gobok:builder
+type Page[T any] struct {
+	Items []T
+}
+
+//gobok:builder
+type Counter struct {
+	N  int
+	mu sync.Mutex
+}
+
+//gobok:builder:staged
+type Account struct {
+	Email string
+}
+
+//gobok:builder
+type Document struct {
+	ToBuilder string
+}
+
+//gobok:builder:flatten
+type Job struct {
+	*Worker
+}
+
+type Worker struct {
+	ID int
+	mu sync.Mutex
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"func NewPageBuilderFrom[T any](src *Page[T]) *PageBuilder[T] {",
+		"instance := *src\n\tinstance.Items = instance.Items[:len(instance.Items):len(instance.Items)]\n\treturn &PageBuilder[T]{instance: &instance}",
+		"func (s Page[T]) ToBuilder() *PageBuilder[T] {\n\treturn NewPageBuilderFrom(&s)\n}",
+		"func NewAccountBuilderFrom(src *Account) *AccountBuilder {",
+		"func (s Account) ToBuilder() *AccountBuilder {",
+		"func NewDocumentBuilderFrom(src *Document) *DocumentBuilder {",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+	if strings.Contains(contentStr, "func (s Document) ToBuilder()") {
+		t.Error("ToBuilder must not clash with a field of the same name")
+	}
+	if strings.Contains(contentStr, "NewCounterBuilderFrom") || strings.Contains(contentStr, "func (s Counter) ToBuilder()") || strings.Contains(contentStr, "NewJobBuilderFrom") {
+		t.Errorf("Expected no builders copying a struct that holds a lock\n%s", contentStr)
+	}
+}
+
+func TestBuilderFromLeavesSourceUntouched(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the generated code")
+	}
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "main.go")
+
+	content := `package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+)
+
+//gobok:builder:flatten
+type Profile struct {
+	*Extra
+	Tags    []string
+	Meta    map[string]string
+	Home    *Address
+	Billing Address
+}
+
+type Extra struct {
+	Note string
+}
+
+//gobok:builder
+type Address struct {
+	City  string
+	Lines []string
+}
+
+func main() {
+	source := Profile{
+		Extra:   &Extra{Note: "note"},
+		Tags:    make([]string, 1, 4),
+		Meta:    map[string]string{"a": "1"},
+		Home:    &Address{City: "Paris"},
+		Billing: Address{Lines: make([]string, 0, 4)},
+	}
+	want := Profile{
+		Extra:   &Extra{Note: "note"},
+		Tags:    make([]string, 1),
+		Meta:    map[string]string{"a": "1"},
+		Home:    &Address{City: "Paris"},
+		Billing: Address{Lines: []string{}},
+	}
+
+	source.ToBuilder().
+		Note("changed").
+		AddTags("b").
+		PutMeta("b", "2").
+		HomeWith(func(b *AddressBuilder) { b.City("Rome") }).
+		BillingWith(func(b *AddressBuilder) { b.AddLines("line") }).
+		Build()
+	other := source.ToBuilder().AddTags("c").Build()
+
+	if !reflect.DeepEqual(source, want) || source.Tags[:2][1] != "" || source.Billing.Lines[:1][0] != "" {
+		fmt.Printf("source changed: %+v\n", source)
+		os.Exit(1)
+	}
+	if other.Tags[1] != "c" {
+		fmt.Printf("unexpected tags: %v\n", other.Tags)
+		os.Exit(1)
+	}
+}`
+
+	files := map[string]string{
+		testFile:                         content,
+		filepath.Join(tempDir, "go.mod"): "module example.com/from\n\ngo 1.22\n",
+	}
+	for file, content := range files {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	before := failures
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+	if failures != before {
+		t.Fatalf("Expected no failures, got %d", failures-before)
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tempDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("Builder from an instance changed it: %v\n%s", err, output)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+
+	expected := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+`
+
+	if result := unifiedDiff("old", "new", []byte(a), []byte(b)); result != expected {
+		t.Errorf("Unexpected diff:\n%s", result)
+	}
+	if result := unifiedDiff("old", "new", []byte(a), []byte(a)); result != "" {
+		t.Errorf("Expected no diff for equal input, got:\n%s", result)
+	}
+	if result := unifiedDiff("old", "new", nil, []byte("one\n")); result != "--- old\n+++ new\n@@ -0,0 +1 @@\n+one\n" {
+		t.Errorf("Unexpected diff against an empty file:\n%s", result)
+	}
+}
+
+func TestCheckBuilders(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+//gobok:builder
+type TestStruct struct {
+	Name string
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	if checkBuilders(tempDir, folders[tempDir]) {
+		t.Error("Expected a missing gobok.go to be reported as stale")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "gobok.go")); !os.IsNotExist(err) {
+		t.Error("Check mode must not write files")
+	}
+
+	writeBuilders(tempDir, folders[tempDir])
+	if !checkBuilders(tempDir, folders[tempDir]) {
+		t.Error("Expected a freshly written gobok.go to be up to date")
+	}
+
+	err = os.WriteFile(filepath.Join(tempDir, "gobok.go"), []byte(generatedPrefix+" v0.0.0. DO NOT EDIT.\n\npackage test\n"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to overwrite generated file: %v", err)
+	}
+	before := failures
+	if checkBuilders(tempDir, folders[tempDir]) {
+		t.Error("Expected a modified gobok.go to be reported as stale")
+	}
+	if failures != before {
+		t.Error("Expected a modified gobok.go to be diffed rather than rejected")
+	}
+}
+
+func TestWriteBuildersUnformattable(t *testing.T) {
+	tempDir := t.TempDir()
+
+	data := &FolderData{
+		PackageName: "test",
+		Imports:     make(map[string]ImportData),
+		HasBuilders: true,
+		Builders: []BuilderData{{
+			StructName:      "Broken",
+			BuilderName:     "Broken",
+			GenerateBuilder: true,
+			Fields:          []FieldData{{Name: "Field", SetterName: "Field", Type: "map["}},
+			output:          "gobok.go",
+		}},
+	}
+
+	before := failures
+	writeBuilders(tempDir, data)
+
+	if failures != before+1 {
+		t.Error("Expected unformattable output to be reported as a failure")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "gobok.go")); !os.IsNotExist(err) {
+		t.Error("Unformattable output must not be written")
+	}
+}
+
+func TestPositioned(t *testing.T) {
+	tests := []struct {
+		pos      token.Position
+		expected string
+	}{
+		{pos: token.Position{}, expected: "message"},
+		{pos: token.Position{Filename: "gobok.go"}, expected: "gobok.go: message"},
+		{pos: token.Position{Filename: "test.go", Line: 4, Column: 6}, expected: "test.go:4:6: message"},
+	}
+
+	for _, tt := range tests {
+		if result := positioned(tt.pos, "message"); result != tt.expected {
+			t.Errorf("positioned(%v) = %q, expected %q", tt.pos, result, tt.expected)
+		}
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"Person":      "person",
+		"HTTPServer":  "http_server",
+		"userID":      "user_id",
+		"OAuth2Token": "o_auth2_token",
+		"X":           "x",
+	}
+
+	for input, expected := range tests {
+		if result := snakeCase(input); result != expected {
+			t.Errorf("snakeCase(%q) = %q, expected %q", input, result, expected)
+		}
+	}
+}
+
+func TestHasLock(t *testing.T) {
+	src := `package test
+
+import "sync"
+
+type Guarded struct {
+	mu sync.Mutex
+}
+
+type Plain struct {
+	Name string
+}
+
+type Broken struct {
+	Field Undefined
+}
+
+type Alias Undefined
+`
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "test.go", src, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse source: %v", err)
+	}
+	conf := types.Config{Importer: importer.Default(), Error: func(error) {}}
+	pkg, _ := conf.Check("test", fset, []*ast.File{file}, nil)
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Guarded", true},
+		{"Plain", false},
+		{"Broken", false},
+		{"Alias", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := hasLock(pkg.Scope().Lookup(test.name).Type()); got != test.want {
+				t.Errorf("hasLock(%s) = %t, want %t", test.name, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPruneImports(t *testing.T) {
+	source := "package p\n\nimport (\n\t\"errors\"\n\tstr \"strings\"\n\t\"time\"\n)\n\nvar _ time.Duration\n"
+
+	pruned, err := pruneImports([]byte(source))
+	if err != nil {
+		t.Fatalf("Failed to prune imports: %v", err)
+	}
+
+	expected := "package p\n\nimport (\n\t\"time\"\n)\n\nvar _ time.Duration\n"
+	if string(pruned) != expected {
+		t.Errorf("Expected %q, got %q", expected, pruned)
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	data := OutputData{Package: "shop", File: "order", Struct: "LineItem"}
+
+	tests := []struct {
+		pattern  string
+		expected string
+		wantErr  bool
+	}{
+		{pattern: "gobok.go", expected: "gobok.go"},
+		{pattern: "{{.Package}}_gobok.go", expected: "shop_gobok.go"},
+		{pattern: "{{.File}}_gobok.go", expected: "order_gobok.go"},
+		{pattern: "{{.Struct | snake}}_builder.go", expected: "line_item_builder.go"},
+		{pattern: "{{.Struct | lower}}.go", expected: "lineitem.go"},
+		{pattern: "{{.Missing}}.go", wantErr: true},
+		{pattern: "{{.Struct", wantErr: true},
+		{pattern: "gen/{{.Struct}}.go", wantErr: true},
+		{pattern: "{{.Struct}}", wantErr: true},
+		{pattern: "{{.File}}_test.go", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		result, err := outputName(tt.pattern, data)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("outputName(%q) = %q, expected an error", tt.pattern, result)
+			}
+			continue
+		}
+		if err != nil || result != tt.expected {
+			t.Errorf("outputName(%q) = %q, %v, expected %q", tt.pattern, result, err, tt.expected)
+		}
+	}
+}
+
+func TestWriteBuildersOutput(t *testing.T) {
+	defer func(pattern string) { outputPattern = pattern }(outputPattern)
+	outputPattern = "{{.File}}_gobok.go"
+
+	tempDir := t.TempDir()
+	orderFile := filepath.Join(tempDir, "order.go")
+	userFile := filepath.Join(tempDir, "user.go")
+
+	order := `package test
+
+import "time"
+
+//gobok:builder
+type Order struct {
+	Placed time.Time
+}
+
+//gobok:builder
+//gobok:output={{.Struct | snake}}_builder.go
+type LineItem struct {
+	Quantity int
+}`
+
+	user := `package test
+
+//gobok:builder
+type User struct {
+	Name string
+}`
+
+	for path, content := range map[string]string{orderFile: order, userFile: user} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	processPackage(tempDir, []string{orderFile, userFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	expected := map[string][]string{
+		"order_gobok.go":       {"type OrderBuilder struct", `"time"`},
+		"line_item_builder.go": {"type LineItemBuilder struct"},
+		"user_gobok.go":        {"type UserBuilder struct"},
+	}
+	for name, wants := range expected {
+		path := filepath.Join(tempDir, name)
+		generatedContent, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read generated file: %v", err)
+		}
+
+		contentStr := string(generatedContent)
+		for _, want := range wants {
+			if !strings.Contains(contentStr, want) {
+				t.Errorf("%s does not contain %q\n%s", name, want, contentStr)
+			}
+		}
+		if name != "order_gobok.go" && strings.Contains(contentStr, `"time"`) {
+			t.Errorf("%s imports a package it does not use\n%s", name, contentStr)
+		}
+		if !isGenerated(path) {
+			t.Errorf("%s is not recognised as generated", name)
+		}
+	}
+
+	if isGenerated(orderFile) {
+		t.Error("A source file is recognised as generated")
+	}
+	if !checkBuilders(tempDir, folders[tempDir]) {
+		t.Error("Expected freshly written files to be up to date")
+	}
+}
+
+func TestWriteBuildersOutputCollision(t *testing.T) {
+	defer func(pattern string) { outputPattern = pattern }(outputPattern)
+	outputPattern = "{{.File}}.go"
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+//gobok:builder
+type TestStruct struct {
+	Name string
+}
+
+//gobok:builder
+//gobok:output=other.go
+type OtherStruct struct {
+	Name string
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	before := failures
+	writeBuilders(tempDir, folders[tempDir])
+
+	if failures != before+1 {
+		t.Error("Expected overwriting a source file to be reported as a failure")
+	}
+	if current, _ := os.ReadFile(testFile); string(current) != content {
+		t.Error("A source file was overwritten")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "other.go")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be written once an output failed")
+	}
+}
+
+func TestWriteBuildersConstraints(t *testing.T) {
+	defer func(ctx build.Context) { buildContext = ctx }(buildContext)
+	buildContext.GOOS = "linux"
+	buildContext.BuildTags = []string{"extra"}
+
+	tempDir := t.TempDir()
+	sources := map[string]string{
+		"common.go": `package test
+
+//gobok:builder
+type Common struct {
+	Name string
+}`,
+		"config_linux.go": `package test
+
+//gobok:builder
+type Config struct {
+	Socket string
+}`,
+		"config_windows.go": `package test
+
+//gobok:builder
+type Config struct {
+	Pipe string
+}`,
+		"extra.go": `//go:build extra && !race
+
+package test
+
+//gobok:builder
+type Extra struct {
+	Flag bool
+}`,
+		"legacy.go": `// +build ignore
+
+package test
+
+//gobok:builder
+type Legacy struct{}`,
+	}
+
+	var files []string
+	for name, content := range sources {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		files = append(files, path)
+	}
+	sort.Strings(files)
+
+	processPackage(tempDir, files)
+
+	var names []string
+	for _, builder := range folders[tempDir].Builders {
+		names = append(names, builder.StructName+"@"+builder.output)
+	}
+	expectedNames := []string{"Common@gobok.go", "Config@gobok_config_linux.go", "Extra@gobok_extra.go"}
+	if strings.Join(names, " ") != strings.Join(expectedNames, " ") {
+		t.Fatalf("Expected builders %v, got %v", expectedNames, names)
+	}
+
+	writeBuilders(tempDir, folders[tempDir])
+
+	expected := map[string]string{
+		"gobok.go":              "DO NOT EDIT.\n\npackage test\n",
+		"gobok_config_linux.go": "DO NOT EDIT.\n\npackage test\n",
+		"gobok_extra.go":        "DO NOT EDIT.\n\n//go:build extra && !race\n\npackage test\n",
+	}
+	for name, want := range expected {
+		generatedContent, err := os.ReadFile(filepath.Join(tempDir, name))
+		if err != nil {
+			t.Fatalf("Failed to read generated file: %v", err)
+		}
+		if !strings.Contains(string(generatedContent), want) {
+			t.Errorf("%s does not contain %q\n%s", name, want, generatedContent)
+		}
+	}
+}
+
+func TestFileConstraint(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{source: "package test\n", expected: ""},
+		{source: "//go:build linux && amd64\n\npackage test\n", expected: "linux && amd64"},
+		{source: "// +build linux darwin\n// +build !cgo\n\npackage test\n", expected: "(linux || darwin) && !cgo"},
+		{source: "package test\n\n//go:build ignored\n", expected: ""},
+	}
+
+	for _, tt := range tests {
+		node, err := parser.ParseFile(token.NewFileSet(), "test.go", tt.source, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.source, err)
+		}
+		if result := fileConstraint(node); result != tt.expected {
+			t.Errorf("fileConstraint(%q) = %q, expected %q", tt.source, result, tt.expected)
+		}
+	}
+
+	if !impliesConstraint("config_linux.go") || !impliesConstraint("asm_linux_arm64.go") {
+		t.Error("Expected GOOS and GOARCH file name suffixes to imply a constraint")
+	}
+	if impliesConstraint("config.go") || impliesConstraint("linux.go") {
+		t.Error("Expected plain file names not to imply a constraint")
+	}
+}
+
+func TestStaleOutputs(t *testing.T) {
+	defer func(ctx build.Context) { buildContext = ctx }(buildContext)
+	buildContext.GOOS = "linux"
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	files := map[string]string{
+		"test.go":          "package test\n\n//gobok:builder\ntype TestStruct struct {\n\tName string\n}\n",
+		"gobok.go":         "// Code generated by gobok v1.0.0. DO NOT EDIT.\n\npackage test\n",
+		"old_gobok.go":     "// Code generated by gobok v1.0.0. DO NOT EDIT.\n\npackage test\n",
+		"gobok_windows.go": "// Code generated by gobok v1.0.0. DO NOT EDIT.\n\npackage test\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	generated := []string{
+		filepath.Join(tempDir, "gobok.go"),
+		filepath.Join(tempDir, "gobok_windows.go"),
+		filepath.Join(tempDir, "old_gobok.go"),
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	stale := staleOutputs(folders[tempDir], generated)
+	if len(stale) != 1 || stale[0] != generated[2] {
+		t.Errorf("Expected only old_gobok.go to be stale, got %v", stale)
+	}
+
+	stale = staleOutputs(nil, generated)
+	if len(stale) != 2 || stale[0] != generated[0] || stale[1] != generated[2] {
+		t.Errorf("Expected every generated file for the platform to be stale without builders, got %v", stale)
+	}
+
+	if handleOrphans(stale, true, false) {
+		t.Error("Expected files no longer generated to fail a check without -prune")
+	}
+	if handleOrphans(stale, true, true) {
+		t.Error("Expected files no longer generated to fail a check with -prune")
+	}
+	if !handleOrphans(nil, true, false) {
+		t.Error("Expected a check without files to remove to pass")
+	}
+	for _, file := range stale {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("Expected a check to leave %s in place: %v", file, err)
+		}
+	}
+
+	removeOutput(stale[1])
+	if _, err := os.Stat(stale[1]); !os.IsNotExist(err) {
+		t.Error("Expected the stale file to be removed")
+	}
+}
+
+func TestWriteBuildersDryRun(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+//gobok:builder
+type TestStruct struct {
+	Name string
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	defer func(enabled bool) { dryRun = enabled }(dryRun)
+	dryRun = true
+	writeBuilders(tempDir, folders[tempDir])
+
+	if _, err := os.Stat(filepath.Join(tempDir, "gobok.go")); !os.IsNotExist(err) {
+		t.Error("Dry run must not write files")
+	}
+
+	var out strings.Builder
+	printBuilders(&out, tempDir, folders[tempDir])
+
+	if !strings.HasPrefix(out.String(), "// Code generated by gobok") || !strings.Contains(out.String(), "type TestStructBuilder struct") {
+		t.Errorf("Unexpected printed source:\n%s", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "gobok.go")); !os.IsNotExist(err) {
+		t.Error("Printing must not write files")
+	}
+}
+
+func TestPrintBuildersSeveralOutputs(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+//gobok:builder
+type First struct {
+	Name string
+}
+
+//gobok:builder
+//gobok:output=second_gobok.go
+type Second struct {
+	Name string
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	var out strings.Builder
+	printBuilders(&out, tempDir, folders[tempDir])
+
+	printed := out.String()
+	first := strings.Index(printed, "// gobok.go\n// Code generated by gobok")
+	second := strings.Index(printed, "\n\n// second_gobok.go\n// Code generated by gobok")
+	if first != 0 || second < 0 || strings.Index(printed, "type SecondBuilder struct") < second {
+		t.Errorf("Expected each printed file to be preceded by its name:\n%s", printed)
+	}
+}
+
+func TestProcessFileGrouped(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+type (
+	// First is documented.
+	//gobok:builder
+	First struct {
+		Name string
+	}
+
+	Skipped struct {
+		Name string
+	}
+
+	//gobok:constructor
+	Second struct {
+		Age int
+	}
+)
+
+//gobok:clone
+type (
+	//gobok:builder
+	Third struct {
+		Tags []string
+	}
+
+	Fourth struct {
+		Count int
+	}
+
+	ID string
+
+	Alias = Fourth
+)`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+
+	var names []string
+	for _, builder := range folders[tempDir].Builders {
+		names = append(names, fmt.Sprintf("%s:%t:%t:%t", builder.StructName, builder.GenerateBuilder, builder.GenerateConstructor, builder.GenerateClone))
+	}
+
+	expected := []string{
+		"First:true:false:false",
+		"Second:false:true:false",
+		"Third:true:false:true",
+		"Fourth:false:false:true",
+	}
+	if strings.Join(names, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+}
+
+func TestWriteBuildersHygiene(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import "time"
+
+type key string
+
+func fn() {}
+
+//gobok:builder:immutable
+//gobok:builder:validate
+//gobok:constructor:name=MakeEvent
+//gobok:options
+//gobok:clone
+type Event[s any] struct {
+	time   time.Time ` + "`gobok:\"include\"`" + `
+	b      int       ` + "`gobok:\"include,min=1\"`" + `
+	v      string    ` + "`gobok:\"include\"`" + `
+	URL    string
+	url    string ` + "`gobok:\"include\"`" + `
+	Type   string
+	Labels map[key]s
+	Hook   func()
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedFile := filepath.Join(tempDir, "gobok.go")
+	generatedContent, err := os.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"func MakeEvent[s any](time2 time.Time, b int, v string, url string, url2 string, type2 string, labels map[key]s, hook func()) Event[s]",
+		"func (b EventBuilder[s]) PutLabels(k key, v s) EventBuilder[s]",
+		"for key2, value := range b.instance.Labels {",
+		"return func(s2 *Event[s]) {",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+
+	cfg := &packages.Config{Mode: packages.NeedTypes | packages.NeedDeps | packages.NeedImports, Dir: tempDir}
+	pkgs, err := packages.Load(cfg, testFile, generatedFile)
+	if err != nil {
+		t.Fatalf("Failed to load generated code: %v", err)
+	}
+	for _, pkgErr := range pkgs[0].Errors {
+		t.Errorf("Generated code does not compile: %v", pkgErr)
+	}
+}
+
+func TestWriteBuildersPrefix(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"doc.go": `//gobok:prefix=Set
+
+// Package test configures setter names for the whole package.
+package test
+`,
+		"test.go": `package test
+
+//gobok:builder
+type Config struct {
+	Name  string
+	Build int
+}
+
+//gobok:builder:prefix=With
+//gobok:builder:staged
+type Request struct {
+	URL   string ` + "`gobok:\"required\"`" + `
+	Tags  []string
+	Limit *int
+}
+
+func (b *RequestBuilder) AddTags(tag string) *RequestBuilder {
+	return b.WithTags(append(b.instance.Tags, tag))
+}`,
+	}
+
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	processPackage(tempDir, paths)
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"func (b *ConfigBuilder) SetName(v string) *ConfigBuilder",
+		"func (b *ConfigBuilder) SetBuild(v int) *ConfigBuilder",
+		"WithURL(v string) *RequestBuilder",
+		"func (b *RequestBuilder) WithTags(v []string) *RequestBuilder",
+		"func (b *RequestBuilder) LimitValue(v int) *RequestBuilder",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+	if strings.Contains(contentStr, ") AddTags(") {
+		t.Errorf("Expected the hand-written AddTags to win over the generated one\n%s", contentStr)
+	}
+}
+
+func TestProcessPackageSetterCollisions(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{name: "fixed method", source: "//gobok:builder\ntype Job struct {\n\tBuild string\n}\n"},
+		{name: "reserved method", source: "//gobok:builder\ntype Job struct {\n\tReset bool\n}\n"},
+		{name: "hand-written method", source: "//gobok:builder\ntype Job struct {\n\tDescribe string\n}\n\nfunc (b *JobBuilder) Describe() string { return \"\" }\n"},
+		{name: "shared setter", source: "//gobok:builder\ntype Job struct {\n\tName string\n\tname string `gobok:\"include\"`\n}\n"},
+		{name: "invalid prefix", source: "//gobok:builder:prefix=With-\ntype Job struct {\n\tName string\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "test.go")
+
+			err := os.WriteFile(testFile, []byte("package test\n\n"+tt.source), 0644)
+			if err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			before := failures
+			processPackage(tempDir, []string{testFile})
+
+			if len(folders[tempDir].Builders) != 0 {
+				t.Errorf("Expected the struct to be rejected, got %v", folders[tempDir].Builders)
+			}
+			if failures != before+1 {
+				t.Errorf("Expected the rejected struct to be reported as a failure")
+			}
+		})
+	}
+}
+
+func TestWriteBuildersConstructors(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import "sync"
+
+//gobok:builder
+//gobok:constructor
+//gobok:constructor:name=NewNamedUser:fields=Email,Name
+//gobok:constructor:name=NewAnonymousUser:exclude=Name,Email,cache
+type User struct {
+	Name   string
+	Email  string
+	Role   string ` + "`gobok:\"default=\\\"member\\\"\"`" + `
+	Secret string ` + "`gobok:\"-\"`" + `
+	cache  map[string]string
+	mu     sync.Mutex
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedContent, err := os.ReadFile(filepath.Join(tempDir, "gobok.go"))
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"func NewUser(name string, email string, role string) User",
+		"func NewNamedUser(email string, name string) User {\n\treturn User{\n\t\tEmail: email,\n\t\tName:  name,\n\t\tRole:  \"member\",\n\t}\n}",
+		"func NewAnonymousUser(role string) User",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+	if strings.Contains(contentStr, "Secret") {
+		t.Errorf("Expected the field tagged gobok:\"-\" to be left out\n%s", contentStr)
+	}
+}
+
+func TestWriteBuildersConstructorVariants(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+
+	content := `package test
+
+import (
+	"errors"
+	"time"
+)
+
+//gobok:constructor:pointer
+//gobok:constructor:name=NewCheckedServer:validate
+type Server struct {
+	Host string ` + "`gobok:\"required\"`" + `
+	Port int    ` + "`gobok:\"min=1\"`" + `
+}
+
+//gobok:constructor:validate
+type Client struct {
+	Name string
+}
+
+func (c *Client) Validate() error {
+	return errors.New("invalid")
+}
+
+//gobok:constructor:validate
+type Event struct {
+	Reflect int
+	When    time.Time ` + "`gobok:\"nonzero\"`" + `
+}`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	processPackage(tempDir, []string{testFile})
+	writeBuilders(tempDir, folders[tempDir])
+
+	generatedFile := filepath.Join(tempDir, "gobok.go")
+	generatedContent, err := os.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatalf("Failed to read generated file: %v", err)
+	}
+
+	contentStr := string(generatedContent)
+	expected := []string{
+		"func NewServer(host string, port int) *Server {\n\treturn &Server{",
+		"func NewCheckedServer(host string, port int) (*Server, error) {\n\ts := &Server{",
+		`if s.Host == "" {`,
+		`errs = append(errs, errors.New("Server.Host must be set"))`,
+		"if s.Port < 1 {",
+		"if err := errors.Join(errs...); err != nil {\n\t\treturn nil, err\n\t}\n\treturn s, nil",
+		"func NewClient(name string) (*Client, error)",
+		"if err := s.Validate(); err != nil {",
+		"func NewEvent(reflect2 int, when time.Time) (*Event, error)",
+		"if reflect.ValueOf(&s.When).Elem().IsZero() {",
+	}
+	for _, want := range expected {
+		if !strings.Contains(contentStr, want) {
+			t.Errorf("Generated file does not contain %q\n%s", want, contentStr)
+		}
+	}
+	if strings.Count(contentStr, ".Validate()") != 1 {
+		t.Errorf("Expected Validate to be called only for Client\n%s", contentStr)
+	}
+
+	cfg := &packages.Config{Mode: packages.NeedTypes | packages.NeedDeps | packages.NeedImports, Dir: tempDir}
+	pkgs, err := packages.Load(cfg, testFile, generatedFile)
+	if err != nil {
+		t.Fatalf("Failed to load generated code: %v", err)
+	}
+	for _, pkgErr := range pkgs[0].Errors {
+		t.Errorf("Generated code does not compile: %v", pkgErr)
+	}
+}
+
+func TestProcessPackageInvalidConstructors(t *testing.T) {
+	tests := []struct {
+		name      string
+		directive string
+	}{
+		{name: "unknown field", directive: "//gobok:constructor:fields=Name,Missing"},
+		{name: "excluded field", directive: "//gobok:constructor:fields=secret"},
+		{name: "unknown excluded field", directive: "//gobok:constructor:exclude=Missing"},
+		{name: "field listed twice", directive: "//gobok:constructor:fields=Name,Name"},
+		{name: "unknown option", directive: "//gobok:constructor:order=Name"},
+		{name: "pointer with a value", directive: "//gobok:constructor:pointer=true"},
+		{name: "validate with a value", directive: "//gobok:constructor:validate=Name"},
+		{name: "invalid name", directive: "//gobok:constructor:name=New-User"},
+		{name: "duplicate name", directive: "//gobok:constructor\n//gobok:constructor:fields=Name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "test.go")
+
+			content := "package test\n\n" + tt.directive + "\ntype User struct {\n\tName   string\n\tsecret string\n}\n"
+			err := os.WriteFile(testFile, []byte(content), 0644)
+			if err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			before := failures
+			processPackage(tempDir, []string{testFile})
+
+			if len(folders[tempDir].Builders) != 0 {
+				t.Errorf("Expected the struct to be rejected, got %v", folders[tempDir].Builders)
+			}
+			if failures != before+1 {
+				t.Errorf("Expected the rejected struct to be reported as a failure")
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"go/types"
+	"strings"
+)
+
+// NestedName returns the name of the setter building a nested struct field
+// through its own builder.
+func (f FieldData) NestedName() string {
+	return capitalizeFirst(f.Name) + "With"
+}
+
+// linkNestedBuilders points the fields of the builder whose type, or pointed
+// to type, has a builder of its own at that builder, so that they get a
+// closure setter configuring the nested value. Pointed to values are copied
+// first, which needs the nested builder's From function.
+func (f *FolderData) linkNestedBuilders(builder *BuilderData) {
+	methods := make(map[string]bool)
+	for name := range builder.reserved {
+		methods[name] = true
+	}
+	for _, field := range append(builder.Fields, builder.Promoted...) {
+		methods[field.SetterName] = true
+		if field.Elem != "" {
+			methods[field.AddName()] = true
+		}
+		if field.Key != "" {
+			methods[field.PutName()] = true
+		}
+		if field.Pointee != "" {
+			methods[field.ValueName()] = true
+		}
+	}
+
+	for i := range builder.Fields {
+		field := &builder.Fields[i]
+		if methods[field.NestedName()] {
+			continue
+		}
+
+		t := builder.fieldTypes[field.Name]
+		pointer, isPointer := t.(*types.Pointer)
+		if isPointer {
+			t = pointer.Elem()
+		}
+
+		named, ok := t.(*types.Named)
+		if !ok {
+			continue
+		}
+		nested, ok := f.nestableBuilder(named)
+		if !ok {
+			continue
+		}
+		if isPointer && !nested.GenerateFrom {
+			// The pointed to struct holds a lock, so a builder made from an
+			// existing instance could neither copy it nor leave it untouched
+			continue
+		}
+
+		typeArgs := f.typeArgsToString(named.TypeArgs())
+		field.Nested = nested.BuilderType() + typeArgs
+		if nested.GenerateFrom {
+			field.NestedFrom = nested.NewBuilderName() + "From" + typeArgs
+		}
+		field.NestedPointer = isPointer
+	}
+}
+
+// nestableBuilder returns the builder generated for named when it can be
+// driven in place: a plain builder that is neither staged, validating nor
+// immutable, so that skipping its Build method loses nothing.
+func (f *FolderData) nestableBuilder(named *types.Named) (BuilderData, bool) {
+	origin := named.Origin().Obj()
+	for _, builder := range f.Builders {
+		if builder.named.Obj() != origin {
+			continue
+		}
+		nestable := builder.GenerateBuilder && !builder.Staged && !builder.Validate && !builder.Immutable
+		return builder, nestable
+	}
+	return BuilderData{}, false
+}
+
+// typeArgsToString renders the type arguments of an instantiated type.
+func (f *FolderData) typeArgsToString(list *types.TypeList) string {
+	if list.Len() == 0 {
+		return ""
+	}
+
+	var args []string
+	for i := 0; i < list.Len(); i++ {
+		args = append(args, f.typeString(list.At(i)))
+	}
+	return "[" + strings.Join(args, ", ") + "]"
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// defaultOutput is the file the generated code goes to unless the -output
+// flag or a //gobok:output directive says otherwise.
+const defaultOutput = "gobok.go"
+
+// generatedPrefix starts the header of every file gobok writes. Files are
+// recognised as generated by it rather than by name, since output names are
+// configurable.
+const generatedPrefix = "// Code generated by gobok"
+
+// outputPattern is the name template for output files set by the -output
+// flag.
+var outputPattern = defaultOutput
+
+// OutputData is what output name templates are executed with.
+type OutputData struct {
+	Package string // Package name
+	File    string // Base name of the source file, without the .go extension
+	Struct  string // Name of the struct
+}
+
+var outputFuncs = template.FuncMap{
+	"snake": snakeCase,
+	"lower": strings.ToLower,
+}
+
+// parseOutputPattern parses a name template for output files.
+func parseOutputPattern(pattern string) (*template.Template, error) {
+	return template.New("output").Funcs(outputFuncs).Option("missingkey=error").Parse(pattern)
+}
+
+// outputName expands the name template for the struct, checking that the
+// result is a plain Go file name inside the package directory.
+func outputName(pattern string, data OutputData) (string, error) {
+	tmpl, err := parseOutputPattern(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid output name %q: %w", pattern, err)
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid output name %q: %w", pattern, err)
+	}
+
+	name := buf.String()
+	switch {
+	case name != filepath.Base(name) || strings.ContainsAny(name, `/\`):
+		return "", fmt.Errorf("output name %q must not contain a directory", name)
+	case !strings.HasSuffix(name, ".go") || name == ".go":
+		return "", fmt.Errorf("output name %q must end in .go", name)
+	case strings.HasSuffix(name, "_test.go"):
+		return "", fmt.Errorf("output name %q must not be a test file", name)
+	}
+
+	return name, nil
+}
+
+// snakeCase converts a Go identifier to snake case, keeping initialisms
+// together: HTTPServer becomes http_server.
+func snakeCase(s string) string {
+	runes := []rune(s)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+// isGenerated reports whether the Go file at path was written by gobok,
+// judging by the comments preceding its package clause.
+func isGenerated(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, generatedPrefix) {
+			return true
+		}
+		if strings.HasPrefix(line, "package ") {
+			return false
+		}
+	}
+
+	return false
+}
+
+// pruneImports drops the imports a generated file does not use. Aliases are
+// allocated for the whole package, but the builders sharing one output file
+// need only some of them.
+func pruneImports(source []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", source, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleting an import shifts the ones after it, so a copy is ranged over
+	for _, spec := range slices.Clone(file.Imports) {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		// Imports are only aliased when their name differs from the last
+		// element of the path, which is what UsesImport assumes otherwise
+		if !astutil.UsesImport(file, importPath) {
+			astutil.DeleteNamedImport(fset, file, name, importPath)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
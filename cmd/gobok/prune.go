@@ -0,0 +1,36 @@
+package main
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+)
+
+// staleOutputs returns the previously generated files of a folder that the
+// current run no longer generates. Files excluded by build constraints
+// belong to another platform or set of tags and are left alone.
+func staleOutputs(data *FolderData, generated []string) []string {
+	current := make(map[string]bool)
+	if data != nil && data.HasBuilders {
+		names, _ := data.outputs()
+		for _, name := range names {
+			current[name] = true
+		}
+	}
+
+	var stale []string
+	for _, file := range matchFiles(generated) {
+		if !current[filepath.Base(file)] {
+			stale = append(stale, file)
+		}
+	}
+	return stale
+}
+
+// removeOutput deletes a file generated by an earlier run.
+func removeOutput(path string) {
+	logf("Removing file: %s", path)
+	if err := os.Remove(path); err != nil {
+		reportError(token.Position{Filename: path}, "failed to remove file: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+)
+
+// logOutput receives progress messages. It is stderr when the generated code
+// itself goes to stdout.
+var logOutput io.Writer = os.Stdout
+
+// failures counts the errors reported so far. Any failure makes gobok exit
+// with a non-zero status and, unless -keep-going is set, keeps it from
+// writing generated files.
+var failures int
+
+// logf prints a progress message.
+func logf(format string, args ...any) {
+	fmt.Fprintf(logOutput, "[gobok] "+format+"\n", args...)
+}
+
+// reportError prints an error to stderr, prefixed with its position when
+// known, and records it as a failure.
+func reportError(pos token.Position, format string, args ...any) {
+	fmt.Fprintln(os.Stderr, positioned(pos, fmt.Sprintf(format, args...)))
+	failures++
+}
+
+// reportWarning prints a warning to stderr without failing the run.
+func reportWarning(pos token.Position, format string, args ...any) {
+	fmt.Fprintln(os.Stderr, positioned(pos, "warning: "+fmt.Sprintf(format, args...)))
+}
+
+func positioned(pos token.Position, msg string) string {
+	if pos == (token.Position{}) {
+		return msg
+	}
+	return pos.String() + ": " + msg
+}
+
+// fieldError is an error about a single field of a struct, reported at the
+// position of the field rather than of the struct.
+type fieldError struct {
+	pos token.Position
+	err error
+}
+
+func (e *fieldError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
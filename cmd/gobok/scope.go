@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+)
+
+// Idents holds the identifiers declared by the functions generated for a
+// struct. They are the usual short names unless those would clash with
+// something the generated code refers to.
+type Idents struct {
+	Receiver string // Receiver of builder methods
+	Self     string // Receiver of methods on the struct itself
+	Value    string // Setter parameter
+	Key      string // Map key parameter of Put setters
+	Map      string // Map copied by immutable Put setters
+	MapKey   string // Range key while copying a map
+	MapValue string // Range value while copying a map
+	Func     string // Closure parameter of nested setters
+	Nested   string // Nested builder
+	Embedded string // Copy of an embedded struct
+	Errs     string // Validation errors collected by Build
+	Err      string
+	Instance string // Copy of the instance
+	Source   string // Instance a builder starts from
+	Copy     string // Shallow copy made by Clone
+	Opts     string // Functional options
+	Opt      string
+}
+
+// scope allocates the identifiers declared by a generated function. A name
+// is only handed out when it is no keyword and shadows neither a predeclared
+// identifier, an import, a package-level declaration nor a name declared
+// before in the same scope, which includes the type parameters.
+type scope struct {
+	folder *FolderData
+	taken  map[string]bool
+}
+
+func (f *FolderData) newScope(typeParams *types.TypeParamList) *scope {
+	s := &scope{folder: f, taken: make(map[string]bool)}
+	for i := 0; i < typeParams.Len(); i++ {
+		s.taken[typeParams.At(i).Obj().Name()] = true
+	}
+	return s
+}
+
+// declare returns name, or name with the smallest numeric suffix that makes
+// it available, and reserves it.
+func (s *scope) declare(name string) string {
+	name = s.avoid(name)
+	s.taken[name] = true
+	return name
+}
+
+// avoid is like declare without reserving the name, for identifiers of
+// sibling blocks that may reuse it.
+func (s *scope) avoid(name string) string {
+	candidate := name
+	for n := 2; s.unavailable(candidate); n++ {
+		candidate = fmt.Sprintf("%s%d", name, n)
+	}
+	return candidate
+}
+
+func (s *scope) unavailable(name string) bool {
+	return s.taken[name] || token.IsKeyword(name) || types.Universe.Lookup(name) != nil || s.folder.nameTaken(name)
+}
+
+// idents allocates the identifiers of the functions generated for a struct
+// with the given type parameters. They all come from one scope, so that any
+// of them may be declared in the same function.
+func (f *FolderData) idents(typeParams *types.TypeParamList) Idents {
+	s := f.newScope(typeParams)
+	return Idents{
+		Receiver: s.declare("b"),
+		Self:     s.declare("s"),
+		Value:    s.declare("v"),
+		Key:      s.declare("k"),
+		Map:      s.declare("m"),
+		MapKey:   s.declare("key"),
+		MapValue: s.declare("value"),
+		Func:     s.declare("fn"),
+		Nested:   s.declare("nested"),
+		Embedded: s.declare("embedded"),
+		Errs:     s.declare("errs"),
+		Err:      s.declare("err"),
+		Instance: s.declare("instance"),
+		Source:   s.declare("src"),
+		Copy:     s.declare("c"),
+		Opts:     s.declare("opts"),
+		Opt:      s.declare("opt"),
+	}
+}
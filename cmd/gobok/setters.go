@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// setterPrefix is prepended to the setter names of every builder, as set by
+// the -prefix flag. A //gobok:prefix= directive above the package clause
+// overrides it for a package and //gobok:builder:prefix= for a single struct.
+var setterPrefix string
+
+// fixedMethods are the builder methods setters must never be named like.
+var fixedMethods = []string{"Build", "Reset"}
+
+// validPrefix reports whether prefix can start a Go identifier.
+func validPrefix(prefix string) bool {
+	return prefix == "" || token.IsIdentifier(prefix)
+}
+
+// packagePrefix returns the setter prefix configured for the package by a
+// //gobok:prefix= directive in the comments preceding a package clause,
+// failing when the files of the package disagree.
+func packagePrefix(fset *token.FileSet, files []*ast.File) (string, error) {
+	prefix, found := setterPrefix, ""
+	for _, file := range files {
+		for _, group := range file.Comments {
+			if group.Pos() >= file.Package {
+				break
+			}
+			for _, comment := range group.List {
+				value, ok := strings.CutPrefix(strings.TrimSpace(comment.Text), "//gobok:prefix=")
+				if !ok {
+					continue
+				}
+
+				pos := fset.Position(comment.Pos())
+				if !validPrefix(value) {
+					return "", fmt.Errorf("%s: invalid setter prefix %q", pos, value)
+				}
+				if found != "" && value != prefix {
+					return "", fmt.Errorf("%s: setter prefix %q conflicts with %q configured at %s", pos, value, prefix, found)
+				}
+				prefix, found = value, pos.String()
+			}
+		}
+	}
+	return prefix, nil
+}
+
+// declaredMethods collects the names of the methods declared in the files,
+// by receiver type name. Builders are declared in the generated code, which
+// does not take part in type checking, so methods added to them by hand are
+// only found in the syntax.
+func declaredMethods(files []*ast.File) map[string]map[string]bool {
+	methods := make(map[string]map[string]bool)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 {
+				continue
+			}
+
+			recv := funcDecl.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			switch expr := recv.(type) {
+			case *ast.IndexExpr:
+				recv = expr.X
+			case *ast.IndexListExpr:
+				recv = expr.X
+			}
+
+			ident, ok := recv.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			if methods[ident.Name] == nil {
+				methods[ident.Name] = make(map[string]bool)
+			}
+			methods[ident.Name][funcDecl.Name.Name] = true
+		}
+	}
+	return methods
+}
+
+// reservedMethods returns the method names of the builder that setters may
+// not take: the fixed ones and those declared by hand.
+func (f *FolderData) reservedMethods(builder *BuilderData) map[string]bool {
+	reserved := make(map[string]bool)
+	for _, name := range fixedMethods {
+		reserved[name] = true
+	}
+	for name := range f.methods[builder.BuilderType()] {
+		reserved[name] = true
+	}
+	return reserved
+}
+
+// checkSetterNames fails when two fields share a setter or a setter takes
+// the name of a reserved method.
+func (b *BuilderData) checkSetterNames() error {
+	owners := make(map[string]string)
+	for _, field := range b.Fields {
+		if b.reserved[field.SetterName] {
+			return fmt.Errorf("setter %s of field %s collides with method %s.%s, set a prefix with //gobok:builder:prefix=", field.SetterName, field.Name, b.BuilderType(), field.SetterName)
+		}
+		if owner, exists := owners[field.SetterName]; exists {
+			return fmt.Errorf("fields %s and %s share the setter %s", owner, field.Name, field.SetterName)
+		}
+		owners[field.SetterName] = field.Name
+	}
+	return nil
+}
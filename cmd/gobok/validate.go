@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// CheckData is a single validation performed by a validating Build method
+// or constructor.
+type CheckData struct {
+	Field   string // Field the check reads
+	Message string // Quoted error message
+
+	// Text around the field that makes up the Go expression that is true when
+	// the check fails
+	before, after string
+}
+
+// Condition returns the Go expression that is true when the check fails,
+// reading the field through prefix.
+func (c CheckData) Condition(prefix string) string {
+	return c.before + prefix + c.Field + c.after
+}
+
+// validationRules lists the tag options that translate into checks.
+var validationRules = []string{"required", "nonzero", "min", "max"}
+
+// validationChecks translates the required tag and the validation rules of a
+// field into checks.
+func (f *FolderData) validationChecks(structName string, field *types.Var, tag string) ([]CheckData, error) {
+	var checks []CheckData
+	subject := structName + "." + field.Name()
+
+	for _, rule := range validationRules {
+		value, ok := tagOption(tag, rule)
+		if !ok {
+			continue
+		}
+
+		switch rule {
+		case "required", "nonzero":
+			before, after := f.zeroCondition(field.Type())
+			checks = append(checks, CheckData{
+				Field:   field.Name(),
+				Message: strconv.Quote(subject + " must be set"),
+				before:  before,
+				after:   after,
+			})
+		case "min", "max":
+			before, after, err := boundOperand(field.Type(), value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid %s rule: %w", subject, rule, err)
+			}
+
+			op, bound := "<", "at least"
+			if rule == "max" {
+				op, bound = ">", "at most"
+			}
+			if before == "len(" {
+				bound = "of length " + bound
+			}
+
+			checks = append(checks, CheckData{
+				Field:   field.Name(),
+				Message: strconv.Quote(subject + " must be " + bound + " " + value),
+				before:  before,
+				after:   after + " " + op + " " + value,
+			})
+		}
+	}
+
+	return checks, nil
+}
+
+// zeroCondition returns the text around a field that makes up an expression
+// reporting whether it holds the zero value of t, falling back to reflection
+// for structs, arrays and type parameters.
+func (f *FolderData) zeroCondition(t types.Type) (before, after string) {
+	if _, ok := t.(*types.TypeParam); !ok {
+		switch u := t.Underlying().(type) {
+		case *types.Basic:
+			switch {
+			case u.Info()&types.IsString != 0:
+				return "", ` == ""`
+			case u.Info()&types.IsBoolean != 0:
+				return "!", ""
+			case u.Info()&types.IsNumeric != 0:
+				return "", " == 0"
+			case u.Kind() == types.UnsafePointer:
+				return "", " == nil"
+			}
+		case *types.Pointer, *types.Slice, *types.Map, *types.Chan, *types.Signature, *types.Interface:
+			return "", " == nil"
+		}
+	}
+
+	reflectPkg := f.importName("reflect", "reflect")
+	return reflectPkg + ".ValueOf(&", ").Elem().IsZero()"
+}
+
+// boundOperand returns the text around a field that makes up what a min or
+// max rule compares against: the value itself for numbers and its length for
+// strings and collections. The bound must be a literal of a matching kind.
+func boundOperand(t types.Type, value string) (before, after string, err error) {
+	if _, ok := t.(*types.TypeParam); ok {
+		return "", "", fmt.Errorf("type parameter fields can not be bounded")
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return lengthOperand(value)
+		case u.Info()&(types.IsInteger|types.IsFloat) != 0:
+			if err := numericBound(u, value); err != nil {
+				return "", "", err
+			}
+			return "", "", nil
+		}
+	case *types.Slice, *types.Map, *types.Chan, *types.Array:
+		return lengthOperand(value)
+	}
+
+	return "", "", fmt.Errorf("unsupported field type %s", t)
+}
+
+// numericBound checks that value is a number literal the field's basic type
+// can hold, so that comparing the field against it compiles.
+func numericBound(basic *types.Basic, value string) error {
+	expr, err := parser.ParseExpr(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", value)
+	}
+	if unary, ok := expr.(*ast.UnaryExpr); ok && (unary.Op == token.SUB || unary.Op == token.ADD) {
+		expr = unary.X
+	}
+	if lit, ok := expr.(*ast.BasicLit); !ok || (lit.Kind != token.INT && lit.Kind != token.FLOAT) {
+		return fmt.Errorf("%q is not a number", value)
+	}
+
+	// Converting the literal in the universe scope makes go/types report
+	// overflows and truncations for us
+	conversion, err := parser.ParseExpr(basic.Name() + "(" + value + ")")
+	if err != nil {
+		return fmt.Errorf("%q is not a number", value)
+	}
+	if err := types.CheckExpr(token.NewFileSet(), nil, token.NoPos, conversion, nil); err != nil {
+		return fmt.Errorf("%s does not fit in %s", value, basic.Name())
+	}
+	return nil
+}
+
+func lengthOperand(value string) (before, after string, err error) {
+	if _, err := strconv.ParseUint(value, 0, 64); err != nil {
+		return "", "", fmt.Errorf("%q is not a valid length", value)
+	}
+	return "len(", ")", nil
+}
+
+// hasValidateMethod reports whether the struct, or a pointer to it, declares
+// a method `Validate() error`.
+func hasValidateMethod(named *types.Named) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), false, named.Obj().Pkg(), "Validate")
+	method, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+
+	sig := method.Type().(*types.Signature)
+	return sig.Params().Len() == 0 && sig.Results().Len() == 1 &&
+		types.Identical(sig.Results().At(0).Type(), types.Universe.Lookup("error").Type())
+}
@@ -12,6 +12,28 @@ func NewPersonBuilder() *PersonBuilder {
 	}
 }
 
+func NewPersonBuilderFrom(src *Person) *PersonBuilder {
+	if src == nil {
+		return &PersonBuilder{
+			instance: &Person{},
+		}
+	}
+	instance := *src
+	instance.Tags = instance.Tags[:len(instance.Tags):len(instance.Tags)]
+	if instance.Metadata != nil {
+		m := make(map[string]interface{}, len(instance.Metadata))
+		for key, value := range instance.Metadata {
+			m[key] = value
+		}
+		instance.Metadata = m
+	}
+	return &PersonBuilder{instance: &instance}
+}
+
+func (s Person) ToBuilder() *PersonBuilder {
+	return NewPersonBuilderFrom(&s)
+}
+
 func (b *PersonBuilder) Name(v string) *PersonBuilder {
 	b.instance.Name = v
 	return b
@@ -28,15 +50,40 @@ func (b *PersonBuilder) Tags(v []string) *PersonBuilder {
 	b.instance.Tags = v
 	return b
 }
+
+func (b *PersonBuilder) AddTags(v ...string) *PersonBuilder {
+	b.instance.Tags = append(b.instance.Tags, v...)
+	return b
+}
 func (b *PersonBuilder) Metadata(v map[string]interface{}) *PersonBuilder {
 	b.instance.Metadata = v
 	return b
 }
+
+func (b *PersonBuilder) PutMetadata(k string, v interface{}) *PersonBuilder {
+	if b.instance.Metadata == nil {
+		b.instance.Metadata = make(map[string]interface{})
+	}
+	b.instance.Metadata[k] = v
+	return b
+}
 func (b *PersonBuilder) Parent(v *Person) *PersonBuilder {
 	b.instance.Parent = v
 	return b
 }
 
+func (b *PersonBuilder) ParentValue(v Person) *PersonBuilder {
+	b.instance.Parent = &v
+	return b
+}
+
+func (b *PersonBuilder) ParentWith(fn func(*PersonBuilder)) *PersonBuilder {
+	nested := NewPersonBuilderFrom(b.instance.Parent)
+	fn(nested)
+	b.instance.Parent = nested.instance
+	return b
+}
+
 func (b *PersonBuilder) Build() *Person {
 	return b.instance
 }
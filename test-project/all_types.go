@@ -1,6 +1,9 @@
 package main
 
-import "time"
+import (
+	"crypto/sha256"
+	"time"
+)
 
 //gobok:builder
 type AllTypes struct {
@@ -35,6 +38,8 @@ type AllTypes struct {
 	IntArray    []int
 	StringArray []string
 	StructArray []NestedStruct
+	FixedArray  [4]int
+	HashArray   [sha256.Size]byte
 
 	// Map types
 	SimpleMap    map[string]int
@@ -47,6 +52,10 @@ type AllTypes struct {
 	SendChan    chan<- string
 	ReceiveChan <-chan bool
 
+	// Func types
+	Handler   func(name string, args ...interface{}) error
+	Formatter func(string, ...int) (string, error)
+
 	// Nested struct
 	NestedStruct NestedStruct
 }
@@ -0,0 +1,20 @@
+package main
+
+//gobok:clone
+type Limits struct {
+	Burst    *int
+	PerRoute map[string]int
+}
+
+//gobok:builder
+//gobok:clone
+type ServiceConfig struct {
+	Name     string
+	Backends []string
+	Labels   map[string][]string
+	Limits   *Limits
+	Fallback Limits
+	History  [2]*Limits
+	Replicas map[string]*Limits
+	Parent   *ServiceConfig
+}
@@ -0,0 +1,23 @@
+package main
+
+//gobok:builder
+//gobok:constructor:name=CreateAdmin
+type Admin struct {
+	User
+	Level int
+}
+
+//gobok:builder:flatten
+type Moderator struct {
+	*User
+	Address
+	Name     string
+	Sections []string
+}
+
+//gobok:builder:flatten
+//gobok:builder:immutable
+type Guest struct {
+	*Address
+	Expires int64
+}
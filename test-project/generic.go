@@ -0,0 +1,15 @@
+package main
+
+//gobok:builder
+//gobok:constructor
+type Page[T any] struct {
+	Items []T
+	Total int
+}
+
+//gobok:builder
+type Result[T any, E comparable] struct {
+	Value T
+	Code  E
+	Pages map[string]Page[T]
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"errors"
 	"time"
 )
 
@@ -16,6 +17,52 @@ func NewAllTypesBuilder() *AllTypesBuilder {
 	}
 }
 
+func NewAllTypesBuilderFrom(src *AllTypes) *AllTypesBuilder {
+	if src == nil {
+		return &AllTypesBuilder{
+			instance: &AllTypes{},
+		}
+	}
+	instance := *src
+	instance.IntArray = instance.IntArray[:len(instance.IntArray):len(instance.IntArray)]
+	instance.StringArray = instance.StringArray[:len(instance.StringArray):len(instance.StringArray)]
+	instance.StructArray = instance.StructArray[:len(instance.StructArray):len(instance.StructArray)]
+	if instance.SimpleMap != nil {
+		m := make(map[string]int, len(instance.SimpleMap))
+		for key, value := range instance.SimpleMap {
+			m[key] = value
+		}
+		instance.SimpleMap = m
+	}
+	if instance.ComplexMap != nil {
+		m := make(map[string]map[int]string, len(instance.ComplexMap))
+		for key, value := range instance.ComplexMap {
+			m[key] = value
+		}
+		instance.ComplexMap = m
+	}
+	if instance.InterfaceMap != nil {
+		m := make(map[string]interface{}, len(instance.InterfaceMap))
+		for key, value := range instance.InterfaceMap {
+			m[key] = value
+		}
+		instance.InterfaceMap = m
+	}
+	if instance.StructMap != nil {
+		m := make(map[string]NestedStruct, len(instance.StructMap))
+		for key, value := range instance.StructMap {
+			m[key] = value
+		}
+		instance.StructMap = m
+	}
+	instance.NestedStruct = *NewNestedStructBuilderFrom(&instance.NestedStruct).instance
+	return &AllTypesBuilder{instance: &instance}
+}
+
+func (s AllTypes) ToBuilder() *AllTypesBuilder {
+	return NewAllTypesBuilderFrom(&s)
+}
+
 func (b *AllTypesBuilder) BoolValue(v bool) *AllTypesBuilder {
 	b.instance.BoolValue = v
 	return b
@@ -84,18 +131,45 @@ func (b *AllTypesBuilder) BoolPtr(v *bool) *AllTypesBuilder {
 	b.instance.BoolPtr = v
 	return b
 }
+
+func (b *AllTypesBuilder) BoolPtrValue(v bool) *AllTypesBuilder {
+	b.instance.BoolPtr = &v
+	return b
+}
 func (b *AllTypesBuilder) IntPtr(v *int) *AllTypesBuilder {
 	b.instance.IntPtr = v
 	return b
 }
+
+func (b *AllTypesBuilder) IntPtrValue(v int) *AllTypesBuilder {
+	b.instance.IntPtr = &v
+	return b
+}
 func (b *AllTypesBuilder) StringPtr(v *string) *AllTypesBuilder {
 	b.instance.StringPtr = v
 	return b
 }
+
+func (b *AllTypesBuilder) StringPtrValue(v string) *AllTypesBuilder {
+	b.instance.StringPtr = &v
+	return b
+}
 func (b *AllTypesBuilder) StructPtr(v *NestedStruct) *AllTypesBuilder {
 	b.instance.StructPtr = v
 	return b
 }
+
+func (b *AllTypesBuilder) StructPtrValue(v NestedStruct) *AllTypesBuilder {
+	b.instance.StructPtr = &v
+	return b
+}
+
+func (b *AllTypesBuilder) StructPtrWith(fn func(*NestedStructBuilder)) *AllTypesBuilder {
+	nested := NewNestedStructBuilderFrom(b.instance.StructPtr)
+	fn(nested)
+	b.instance.StructPtr = nested.instance
+	return b
+}
 func (b *AllTypesBuilder) TimeValue(v time.Time) *AllTypesBuilder {
 	b.instance.TimeValue = v
 	return b
@@ -104,30 +178,85 @@ func (b *AllTypesBuilder) IntArray(v []int) *AllTypesBuilder {
 	b.instance.IntArray = v
 	return b
 }
+
+func (b *AllTypesBuilder) AddIntArray(v ...int) *AllTypesBuilder {
+	b.instance.IntArray = append(b.instance.IntArray, v...)
+	return b
+}
 func (b *AllTypesBuilder) StringArray(v []string) *AllTypesBuilder {
 	b.instance.StringArray = v
 	return b
 }
+
+func (b *AllTypesBuilder) AddStringArray(v ...string) *AllTypesBuilder {
+	b.instance.StringArray = append(b.instance.StringArray, v...)
+	return b
+}
 func (b *AllTypesBuilder) StructArray(v []NestedStruct) *AllTypesBuilder {
 	b.instance.StructArray = v
 	return b
 }
+
+func (b *AllTypesBuilder) AddStructArray(v ...NestedStruct) *AllTypesBuilder {
+	b.instance.StructArray = append(b.instance.StructArray, v...)
+	return b
+}
+func (b *AllTypesBuilder) FixedArray(v [4]int) *AllTypesBuilder {
+	b.instance.FixedArray = v
+	return b
+}
+func (b *AllTypesBuilder) HashArray(v [32]byte) *AllTypesBuilder {
+	b.instance.HashArray = v
+	return b
+}
 func (b *AllTypesBuilder) SimpleMap(v map[string]int) *AllTypesBuilder {
 	b.instance.SimpleMap = v
 	return b
 }
+
+func (b *AllTypesBuilder) PutSimpleMap(k string, v int) *AllTypesBuilder {
+	if b.instance.SimpleMap == nil {
+		b.instance.SimpleMap = make(map[string]int)
+	}
+	b.instance.SimpleMap[k] = v
+	return b
+}
 func (b *AllTypesBuilder) ComplexMap(v map[string]map[int]string) *AllTypesBuilder {
 	b.instance.ComplexMap = v
 	return b
 }
+
+func (b *AllTypesBuilder) PutComplexMap(k string, v map[int]string) *AllTypesBuilder {
+	if b.instance.ComplexMap == nil {
+		b.instance.ComplexMap = make(map[string]map[int]string)
+	}
+	b.instance.ComplexMap[k] = v
+	return b
+}
 func (b *AllTypesBuilder) InterfaceMap(v map[string]interface{}) *AllTypesBuilder {
 	b.instance.InterfaceMap = v
 	return b
 }
+
+func (b *AllTypesBuilder) PutInterfaceMap(k string, v interface{}) *AllTypesBuilder {
+	if b.instance.InterfaceMap == nil {
+		b.instance.InterfaceMap = make(map[string]interface{})
+	}
+	b.instance.InterfaceMap[k] = v
+	return b
+}
 func (b *AllTypesBuilder) StructMap(v map[string]NestedStruct) *AllTypesBuilder {
 	b.instance.StructMap = v
 	return b
 }
+
+func (b *AllTypesBuilder) PutStructMap(k string, v NestedStruct) *AllTypesBuilder {
+	if b.instance.StructMap == nil {
+		b.instance.StructMap = make(map[string]NestedStruct)
+	}
+	b.instance.StructMap[k] = v
+	return b
+}
 func (b *AllTypesBuilder) IntChan(v chan int) *AllTypesBuilder {
 	b.instance.IntChan = v
 	return b
@@ -140,11 +269,24 @@ func (b *AllTypesBuilder) ReceiveChan(v <-chan bool) *AllTypesBuilder {
 	b.instance.ReceiveChan = v
 	return b
 }
+func (b *AllTypesBuilder) Handler(v func(name string, args ...interface{}) error) *AllTypesBuilder {
+	b.instance.Handler = v
+	return b
+}
+func (b *AllTypesBuilder) Formatter(v func(string, ...int) (string, error)) *AllTypesBuilder {
+	b.instance.Formatter = v
+	return b
+}
 func (b *AllTypesBuilder) NestedStruct(v NestedStruct) *AllTypesBuilder {
 	b.instance.NestedStruct = v
 	return b
 }
 
+func (b *AllTypesBuilder) NestedStructWith(fn func(*NestedStructBuilder)) *AllTypesBuilder {
+	fn(&NestedStructBuilder{instance: &b.instance.NestedStruct})
+	return b
+}
+
 func (b *AllTypesBuilder) Build() *AllTypes {
 	return b.instance
 }
@@ -159,6 +301,20 @@ func NewNestedStructBuilder() *NestedStructBuilder {
 	}
 }
 
+func NewNestedStructBuilderFrom(src *NestedStruct) *NestedStructBuilder {
+	if src == nil {
+		return &NestedStructBuilder{
+			instance: &NestedStruct{},
+		}
+	}
+	instance := *src
+	return &NestedStructBuilder{instance: &instance}
+}
+
+func (s NestedStruct) ToBuilder() *NestedStructBuilder {
+	return NewNestedStructBuilderFrom(&s)
+}
+
 func (b *NestedStructBuilder) Field1(v string) *NestedStructBuilder {
 	b.instance.Field1 = v
 	return b
@@ -172,10 +328,182 @@ func (b *NestedStructBuilder) Field3(v *bool) *NestedStructBuilder {
 	return b
 }
 
+func (b *NestedStructBuilder) Field3Value(v bool) *NestedStructBuilder {
+	b.instance.Field3 = &v
+	return b
+}
+
 func (b *NestedStructBuilder) Build() *NestedStruct {
 	return b.instance
 }
 
+func (s *Limits) Clone() *Limits {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	if s.Burst != nil {
+		v1 := *s.Burst
+		c.Burst = &v1
+	}
+	if s.PerRoute != nil {
+		c.PerRoute = make(map[string]int, len(s.PerRoute))
+		for k1, v1 := range s.PerRoute {
+			c.PerRoute[k1] = v1
+		}
+	}
+	return &c
+}
+
+type ServiceConfigBuilder struct {
+	instance *ServiceConfig
+}
+
+func NewServiceConfigBuilder() *ServiceConfigBuilder {
+	return &ServiceConfigBuilder{
+		instance: &ServiceConfig{},
+	}
+}
+
+func NewServiceConfigBuilderFrom(src *ServiceConfig) *ServiceConfigBuilder {
+	if src == nil {
+		return &ServiceConfigBuilder{
+			instance: &ServiceConfig{},
+		}
+	}
+	instance := *src
+	instance.Backends = instance.Backends[:len(instance.Backends):len(instance.Backends)]
+	if instance.Labels != nil {
+		m := make(map[string][]string, len(instance.Labels))
+		for key, value := range instance.Labels {
+			m[key] = value
+		}
+		instance.Labels = m
+	}
+	if instance.Replicas != nil {
+		m := make(map[string]*Limits, len(instance.Replicas))
+		for key, value := range instance.Replicas {
+			m[key] = value
+		}
+		instance.Replicas = m
+	}
+	return &ServiceConfigBuilder{instance: &instance}
+}
+
+func (s ServiceConfig) ToBuilder() *ServiceConfigBuilder {
+	return NewServiceConfigBuilderFrom(&s)
+}
+
+func (b *ServiceConfigBuilder) Name(v string) *ServiceConfigBuilder {
+	b.instance.Name = v
+	return b
+}
+func (b *ServiceConfigBuilder) Backends(v []string) *ServiceConfigBuilder {
+	b.instance.Backends = v
+	return b
+}
+
+func (b *ServiceConfigBuilder) AddBackends(v ...string) *ServiceConfigBuilder {
+	b.instance.Backends = append(b.instance.Backends, v...)
+	return b
+}
+func (b *ServiceConfigBuilder) Labels(v map[string][]string) *ServiceConfigBuilder {
+	b.instance.Labels = v
+	return b
+}
+
+func (b *ServiceConfigBuilder) PutLabels(k string, v []string) *ServiceConfigBuilder {
+	if b.instance.Labels == nil {
+		b.instance.Labels = make(map[string][]string)
+	}
+	b.instance.Labels[k] = v
+	return b
+}
+func (b *ServiceConfigBuilder) Limits(v *Limits) *ServiceConfigBuilder {
+	b.instance.Limits = v
+	return b
+}
+
+func (b *ServiceConfigBuilder) LimitsValue(v Limits) *ServiceConfigBuilder {
+	b.instance.Limits = &v
+	return b
+}
+func (b *ServiceConfigBuilder) Fallback(v Limits) *ServiceConfigBuilder {
+	b.instance.Fallback = v
+	return b
+}
+func (b *ServiceConfigBuilder) History(v [2]*Limits) *ServiceConfigBuilder {
+	b.instance.History = v
+	return b
+}
+func (b *ServiceConfigBuilder) Replicas(v map[string]*Limits) *ServiceConfigBuilder {
+	b.instance.Replicas = v
+	return b
+}
+
+func (b *ServiceConfigBuilder) PutReplicas(k string, v *Limits) *ServiceConfigBuilder {
+	if b.instance.Replicas == nil {
+		b.instance.Replicas = make(map[string]*Limits)
+	}
+	b.instance.Replicas[k] = v
+	return b
+}
+func (b *ServiceConfigBuilder) Parent(v *ServiceConfig) *ServiceConfigBuilder {
+	b.instance.Parent = v
+	return b
+}
+
+func (b *ServiceConfigBuilder) ParentValue(v ServiceConfig) *ServiceConfigBuilder {
+	b.instance.Parent = &v
+	return b
+}
+
+func (b *ServiceConfigBuilder) ParentWith(fn func(*ServiceConfigBuilder)) *ServiceConfigBuilder {
+	nested := NewServiceConfigBuilderFrom(b.instance.Parent)
+	fn(nested)
+	b.instance.Parent = nested.instance
+	return b
+}
+
+func (b *ServiceConfigBuilder) Build() *ServiceConfig {
+	return b.instance
+}
+
+func (s *ServiceConfig) Clone() *ServiceConfig {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	if s.Backends != nil {
+		c.Backends = make([]string, len(s.Backends))
+		copy(c.Backends, s.Backends)
+	}
+	if s.Labels != nil {
+		c.Labels = make(map[string][]string, len(s.Labels))
+		for k1, v1 := range s.Labels {
+			c1 := v1
+			if v1 != nil {
+				c1 = make([]string, len(v1))
+				copy(c1, v1)
+			}
+			c.Labels[k1] = c1
+		}
+	}
+	c.Limits = s.Limits.Clone()
+	c.Fallback = *s.Fallback.Clone()
+	for i1, v1 := range s.History {
+		c.History[i1] = v1.Clone()
+	}
+	if s.Replicas != nil {
+		c.Replicas = make(map[string]*Limits, len(s.Replicas))
+		for k1, v1 := range s.Replicas {
+			c.Replicas[k1] = v1.Clone()
+		}
+	}
+	c.Parent = s.Parent.Clone()
+	return &c
+}
+
 type AddressBuilder struct {
 	instance *Address
 }
@@ -186,6 +514,20 @@ func NewAddressBuilder() *AddressBuilder {
 	}
 }
 
+func NewAddressBuilderFrom(src *Address) *AddressBuilder {
+	if src == nil {
+		return &AddressBuilder{
+			instance: &Address{},
+		}
+	}
+	instance := *src
+	return &AddressBuilder{instance: &instance}
+}
+
+func (s Address) ToBuilder() *AddressBuilder {
+	return NewAddressBuilderFrom(&s)
+}
+
 func (b *AddressBuilder) Street(v string) *AddressBuilder {
 	b.instance.Street = v
 	return b
@@ -213,6 +555,20 @@ func NewContactBuilder() *ContactBuilder {
 	}
 }
 
+func NewContactBuilderFrom(src *Contact) *ContactBuilder {
+	if src == nil {
+		return &ContactBuilder{
+			instance: &Contact{},
+		}
+	}
+	instance := *src
+	return &ContactBuilder{instance: &instance}
+}
+
+func (s Contact) ToBuilder() *ContactBuilder {
+	return NewContactBuilderFrom(&s)
+}
+
 func (b *ContactBuilder) Email(v string) *ContactBuilder {
 	b.instance.Email = v
 	return b
@@ -221,10 +577,27 @@ func (b *ContactBuilder) Phone(v *string) *ContactBuilder {
 	b.instance.Phone = v
 	return b
 }
+
+func (b *ContactBuilder) PhoneValue(v string) *ContactBuilder {
+	b.instance.Phone = &v
+	return b
+}
 func (b *ContactBuilder) Address(v *Address) *ContactBuilder {
 	b.instance.Address = v
 	return b
 }
+
+func (b *ContactBuilder) AddressValue(v Address) *ContactBuilder {
+	b.instance.Address = &v
+	return b
+}
+
+func (b *ContactBuilder) AddressWith(fn func(*AddressBuilder)) *ContactBuilder {
+	nested := NewAddressBuilderFrom(b.instance.Address)
+	fn(nested)
+	b.instance.Address = nested.instance
+	return b
+}
 func (b *ContactBuilder) IsActive(v bool) *ContactBuilder {
 	b.instance.IsActive = v
 	return b
@@ -244,6 +617,28 @@ func NewUserProfileBuilder() *UserProfileBuilder {
 	}
 }
 
+func NewUserProfileBuilderFrom(src *UserProfile) *UserProfileBuilder {
+	if src == nil {
+		return &UserProfileBuilder{
+			instance: &UserProfile{},
+		}
+	}
+	instance := *src
+	instance.Contacts = instance.Contacts[:len(instance.Contacts):len(instance.Contacts)]
+	if instance.Metadata != nil {
+		m := make(map[string]interface{}, len(instance.Metadata))
+		for key, value := range instance.Metadata {
+			m[key] = value
+		}
+		instance.Metadata = m
+	}
+	return &UserProfileBuilder{instance: &instance}
+}
+
+func (s UserProfile) ToBuilder() *UserProfileBuilder {
+	return NewUserProfileBuilderFrom(&s)
+}
+
 func (b *UserProfileBuilder) ID(v int) *UserProfileBuilder {
 	b.instance.ID = v
 	return b
@@ -260,14 +655,32 @@ func (b *UserProfileBuilder) Contacts(v []Contact) *UserProfileBuilder {
 	b.instance.Contacts = v
 	return b
 }
+
+func (b *UserProfileBuilder) AddContacts(v ...Contact) *UserProfileBuilder {
+	b.instance.Contacts = append(b.instance.Contacts, v...)
+	return b
+}
 func (b *UserProfileBuilder) Metadata(v map[string]interface{}) *UserProfileBuilder {
 	b.instance.Metadata = v
 	return b
 }
+
+func (b *UserProfileBuilder) PutMetadata(k string, v interface{}) *UserProfileBuilder {
+	if b.instance.Metadata == nil {
+		b.instance.Metadata = make(map[string]interface{})
+	}
+	b.instance.Metadata[k] = v
+	return b
+}
 func (b *UserProfileBuilder) Settings(v *map[string]string) *UserProfileBuilder {
 	b.instance.Settings = v
 	return b
 }
+
+func (b *UserProfileBuilder) SettingsValue(v map[string]string) *UserProfileBuilder {
+	b.instance.Settings = &v
+	return b
+}
 func (b *UserProfileBuilder) CreatedAt(v int64) *UserProfileBuilder {
 	b.instance.CreatedAt = v
 	return b
@@ -277,6 +690,11 @@ func (b *UserProfileBuilder) UpdatedAt(v *int64) *UserProfileBuilder {
 	return b
 }
 
+func (b *UserProfileBuilder) UpdatedAtValue(v int64) *UserProfileBuilder {
+	b.instance.UpdatedAt = &v
+	return b
+}
+
 func (b *UserProfileBuilder) Build() *UserProfile {
 	return b.instance
 }
@@ -291,6 +709,20 @@ func NewPersonBuilder() *PersonBuilder {
 	}
 }
 
+func NewPersonBuilderFrom(src *Person) *PersonBuilder {
+	if src == nil {
+		return &PersonBuilder{
+			instance: &Person{},
+		}
+	}
+	instance := *src
+	return &PersonBuilder{instance: &instance}
+}
+
+func (s Person) ToBuilder() *PersonBuilder {
+	return NewPersonBuilderFrom(&s)
+}
+
 func (b *PersonBuilder) Name(v string) *PersonBuilder {
 	b.instance.Name = v
 	return b
@@ -304,10 +736,10 @@ func (b *PersonBuilder) Build() *Person {
 	return b.instance
 }
 
-func CreatePerson(Name string, Age int) Person {
+func CreatePerson(name string, age int) Person {
 	return Person{
-		Name: Name,
-		Age:  Age,
+		Name: name,
+		Age:  age,
 	}
 }
 
@@ -321,6 +753,20 @@ func NewEmployeeBuilder() *EmployeeBuilder {
 	}
 }
 
+func NewEmployeeBuilderFrom(src *Employee) *EmployeeBuilder {
+	if src == nil {
+		return &EmployeeBuilder{
+			instance: &Employee{},
+		}
+	}
+	instance := *src
+	return &EmployeeBuilder{instance: &instance}
+}
+
+func (s Employee) ToBuilder() *EmployeeBuilder {
+	return NewEmployeeBuilderFrom(&s)
+}
+
 func (b *EmployeeBuilder) ID(v int) *EmployeeBuilder {
 	b.instance.ID = v
 	return b
@@ -338,14 +784,513 @@ func (b *EmployeeBuilder) Build() *Employee {
 	return b.instance
 }
 
-func NewEmployee(ID int, Title string, Salary float64) Employee {
+func NewEmployee(id int, title string, salary float64) Employee {
 	return Employee{
-		ID:     ID,
-		Title:  Title,
-		Salary: Salary,
+		ID:     id,
+		Title:  title,
+		Salary: salary,
 	}
 }
 
+type AdminBuilder struct {
+	instance *Admin
+}
+
+func NewAdminBuilder() *AdminBuilder {
+	return &AdminBuilder{
+		instance: &Admin{},
+	}
+}
+
+func NewAdminBuilderFrom(src *Admin) *AdminBuilder {
+	if src == nil {
+		return &AdminBuilder{
+			instance: &Admin{},
+		}
+	}
+	instance := *src
+	instance.User = *NewUserBuilderFrom(&instance.User).instance
+	return &AdminBuilder{instance: &instance}
+}
+
+func (s Admin) ToBuilder() *AdminBuilder {
+	return NewAdminBuilderFrom(&s)
+}
+
+func (b *AdminBuilder) User(v User) *AdminBuilder {
+	b.instance.User = v
+	return b
+}
+
+func (b *AdminBuilder) UserWith(fn func(*UserBuilder)) *AdminBuilder {
+	fn(&UserBuilder{instance: &b.instance.User})
+	return b
+}
+func (b *AdminBuilder) Level(v int) *AdminBuilder {
+	b.instance.Level = v
+	return b
+}
+
+func (b *AdminBuilder) Build() *Admin {
+	return b.instance
+}
+
+func CreateAdmin(user User, level int) Admin {
+	return Admin{
+		User:  user,
+		Level: level,
+	}
+}
+
+type ModeratorBuilder struct {
+	instance *Moderator
+}
+
+func NewModeratorBuilder() *ModeratorBuilder {
+	return &ModeratorBuilder{
+		instance: &Moderator{},
+	}
+}
+
+func NewModeratorBuilderFrom(src *Moderator) *ModeratorBuilder {
+	if src == nil {
+		return &ModeratorBuilder{
+			instance: &Moderator{},
+		}
+	}
+	instance := *src
+	instance.Address = *NewAddressBuilderFrom(&instance.Address).instance
+	instance.Sections = instance.Sections[:len(instance.Sections):len(instance.Sections)]
+	if instance.User != nil {
+		c := *instance.User
+		instance.User = &c
+	}
+	return &ModeratorBuilder{instance: &instance}
+}
+
+func (s Moderator) ToBuilder() *ModeratorBuilder {
+	return NewModeratorBuilderFrom(&s)
+}
+
+func (b *ModeratorBuilder) User(v *User) *ModeratorBuilder {
+	b.instance.User = v
+	return b
+}
+
+func (b *ModeratorBuilder) UserValue(v User) *ModeratorBuilder {
+	b.instance.User = &v
+	return b
+}
+
+func (b *ModeratorBuilder) UserWith(fn func(*UserBuilder)) *ModeratorBuilder {
+	nested := NewUserBuilderFrom(b.instance.User)
+	fn(nested)
+	b.instance.User = nested.instance
+	return b
+}
+func (b *ModeratorBuilder) Address(v Address) *ModeratorBuilder {
+	b.instance.Address = v
+	return b
+}
+
+func (b *ModeratorBuilder) AddressWith(fn func(*AddressBuilder)) *ModeratorBuilder {
+	fn(&AddressBuilder{instance: &b.instance.Address})
+	return b
+}
+func (b *ModeratorBuilder) Name(v string) *ModeratorBuilder {
+	b.instance.Name = v
+	return b
+}
+func (b *ModeratorBuilder) Sections(v []string) *ModeratorBuilder {
+	b.instance.Sections = v
+	return b
+}
+
+func (b *ModeratorBuilder) AddSections(v ...string) *ModeratorBuilder {
+	b.instance.Sections = append(b.instance.Sections, v...)
+	return b
+}
+
+func (b *ModeratorBuilder) Age(v int) *ModeratorBuilder {
+	if b.instance.User == nil {
+		b.instance.User = &User{}
+	}
+	b.instance.User.Age = v
+	return b
+}
+func (b *ModeratorBuilder) Tags(v []string) *ModeratorBuilder {
+	if b.instance.User == nil {
+		b.instance.User = &User{}
+	}
+	b.instance.User.Tags = v
+	return b
+}
+func (b *ModeratorBuilder) Street(v string) *ModeratorBuilder {
+	b.instance.Address.Street = v
+	return b
+}
+func (b *ModeratorBuilder) City(v string) *ModeratorBuilder {
+	b.instance.Address.City = v
+	return b
+}
+func (b *ModeratorBuilder) Country(v string) *ModeratorBuilder {
+	b.instance.Address.Country = v
+	return b
+}
+
+func (b *ModeratorBuilder) Build() *Moderator {
+	return b.instance
+}
+
+type GuestBuilder struct {
+	instance Guest
+}
+
+func NewGuestBuilder() GuestBuilder {
+	return GuestBuilder{
+		instance: Guest{},
+	}
+}
+
+func NewGuestBuilderFrom(src *Guest) GuestBuilder {
+	if src == nil {
+		return GuestBuilder{
+			instance: Guest{},
+		}
+	}
+	instance := *src
+	return GuestBuilder{instance: instance}
+}
+
+func (s Guest) ToBuilder() GuestBuilder {
+	return NewGuestBuilderFrom(&s)
+}
+
+func (b GuestBuilder) Address(v *Address) GuestBuilder {
+	b.instance.Address = v
+	return b
+}
+
+func (b GuestBuilder) AddressValue(v Address) GuestBuilder {
+	b.instance.Address = &v
+	return b
+}
+
+func (b GuestBuilder) AddressWith(fn func(*AddressBuilder)) GuestBuilder {
+	nested := NewAddressBuilderFrom(b.instance.Address)
+	fn(nested)
+	b.instance.Address = nested.instance
+	return b
+}
+func (b GuestBuilder) Expires(v int64) GuestBuilder {
+	b.instance.Expires = v
+	return b
+}
+
+func (b GuestBuilder) Street(v string) GuestBuilder {
+	embedded := Address{}
+	if b.instance.Address != nil {
+		embedded = *b.instance.Address
+	}
+	embedded.Street = v
+	b.instance.Address = &embedded
+	return b
+}
+func (b GuestBuilder) City(v string) GuestBuilder {
+	embedded := Address{}
+	if b.instance.Address != nil {
+		embedded = *b.instance.Address
+	}
+	embedded.City = v
+	b.instance.Address = &embedded
+	return b
+}
+func (b GuestBuilder) Country(v string) GuestBuilder {
+	embedded := Address{}
+	if b.instance.Address != nil {
+		embedded = *b.instance.Address
+	}
+	embedded.Country = v
+	b.instance.Address = &embedded
+	return b
+}
+
+func (b GuestBuilder) Build() *Guest {
+	instance := b.instance
+	return &instance
+}
+
+type PageBuilder[T any] struct {
+	instance *Page[T]
+}
+
+func NewPageBuilder[T any]() *PageBuilder[T] {
+	return &PageBuilder[T]{
+		instance: &Page[T]{},
+	}
+}
+
+func NewPageBuilderFrom[T any](src *Page[T]) *PageBuilder[T] {
+	if src == nil {
+		return &PageBuilder[T]{
+			instance: &Page[T]{},
+		}
+	}
+	instance := *src
+	instance.Items = instance.Items[:len(instance.Items):len(instance.Items)]
+	return &PageBuilder[T]{instance: &instance}
+}
+
+func (s Page[T]) ToBuilder() *PageBuilder[T] {
+	return NewPageBuilderFrom(&s)
+}
+
+func (b *PageBuilder[T]) Items(v []T) *PageBuilder[T] {
+	b.instance.Items = v
+	return b
+}
+
+func (b *PageBuilder[T]) AddItems(v ...T) *PageBuilder[T] {
+	b.instance.Items = append(b.instance.Items, v...)
+	return b
+}
+func (b *PageBuilder[T]) Total(v int) *PageBuilder[T] {
+	b.instance.Total = v
+	return b
+}
+
+func (b *PageBuilder[T]) Build() *Page[T] {
+	return b.instance
+}
+
+func NewPage[T any](items []T, total int) Page[T] {
+	return Page[T]{
+		Items: items,
+		Total: total,
+	}
+}
+
+type ResultBuilder[T any, E comparable] struct {
+	instance *Result[T, E]
+}
+
+func NewResultBuilder[T any, E comparable]() *ResultBuilder[T, E] {
+	return &ResultBuilder[T, E]{
+		instance: &Result[T, E]{},
+	}
+}
+
+func NewResultBuilderFrom[T any, E comparable](src *Result[T, E]) *ResultBuilder[T, E] {
+	if src == nil {
+		return &ResultBuilder[T, E]{
+			instance: &Result[T, E]{},
+		}
+	}
+	instance := *src
+	if instance.Pages != nil {
+		m := make(map[string]Page[T], len(instance.Pages))
+		for key, value := range instance.Pages {
+			m[key] = value
+		}
+		instance.Pages = m
+	}
+	return &ResultBuilder[T, E]{instance: &instance}
+}
+
+func (s Result[T, E]) ToBuilder() *ResultBuilder[T, E] {
+	return NewResultBuilderFrom(&s)
+}
+
+func (b *ResultBuilder[T, E]) Value(v T) *ResultBuilder[T, E] {
+	b.instance.Value = v
+	return b
+}
+func (b *ResultBuilder[T, E]) Code(v E) *ResultBuilder[T, E] {
+	b.instance.Code = v
+	return b
+}
+func (b *ResultBuilder[T, E]) Pages(v map[string]Page[T]) *ResultBuilder[T, E] {
+	b.instance.Pages = v
+	return b
+}
+
+func (b *ResultBuilder[T, E]) PutPages(k string, v Page[T]) *ResultBuilder[T, E] {
+	if b.instance.Pages == nil {
+		b.instance.Pages = make(map[string]Page[T])
+	}
+	b.instance.Pages[k] = v
+	return b
+}
+
+func (b *ResultBuilder[T, E]) Build() *Result[T, E] {
+	return b.instance
+}
+
+type RequestBuilder struct {
+	instance Request
+}
+
+func NewRequestBuilder() RequestBuilder {
+	return RequestBuilder{
+		instance: Request{
+			Method: "GET",
+		},
+	}
+}
+
+func NewRequestBuilderFrom(src *Request) RequestBuilder {
+	if src == nil {
+		return RequestBuilder{
+			instance: Request{
+				Method: "GET",
+			},
+		}
+	}
+	instance := *src
+	return RequestBuilder{instance: instance}
+}
+
+func (s Request) ToBuilder() RequestBuilder {
+	return NewRequestBuilderFrom(&s)
+}
+
+func (b RequestBuilder) Method(v string) RequestBuilder {
+	b.instance.Method = v
+	return b
+}
+func (b RequestBuilder) Host(v string) RequestBuilder {
+	b.instance.Host = v
+	return b
+}
+func (b RequestBuilder) Path(v string) RequestBuilder {
+	b.instance.Path = v
+	return b
+}
+
+func (b RequestBuilder) Build() *Request {
+	instance := b.instance
+	return &instance
+}
+
+type RouteBuilder struct {
+	instance Route
+}
+
+type RoutePatternStep interface {
+	Pattern(v string) RouteBuilder
+}
+
+type stagedRouteBuilder struct {
+	instance Route
+}
+
+func NewRouteBuilder() RoutePatternStep {
+	return stagedRouteBuilder{
+		instance: Route{},
+	}
+}
+
+func (b stagedRouteBuilder) Pattern(v string) RouteBuilder {
+	b.instance.Pattern = v
+	return RouteBuilder{instance: b.instance}
+}
+
+func NewRouteBuilderFrom(src *Route) RouteBuilder {
+	if src == nil {
+		return RouteBuilder{
+			instance: Route{},
+		}
+	}
+	instance := *src
+	return RouteBuilder{instance: instance}
+}
+
+func (s Route) ToBuilder() RouteBuilder {
+	return NewRouteBuilderFrom(&s)
+}
+
+func (b RouteBuilder) Handler(v string) RouteBuilder {
+	b.instance.Handler = v
+	return b
+}
+
+func (b RouteBuilder) Build() (*Route, error) {
+	var errs []error
+	if b.instance.Pattern == "" {
+		errs = append(errs, errors.New("Route.Pattern must be set"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	instance := b.instance
+	return &instance, nil
+}
+
+type DialerOption func(*Dialer)
+
+func WithNetwork(v string) DialerOption {
+	return func(s *Dialer) {
+		s.Network = v
+	}
+}
+func WithKeepAlive(v time.Duration) DialerOption {
+	return func(s *Dialer) {
+		s.KeepAlive = v
+	}
+}
+func WithRetries(v int) DialerOption {
+	return func(s *Dialer) {
+		s.Retries = v
+	}
+}
+
+func NewDialer(opts ...DialerOption) *Dialer {
+	s := &Dialer{
+		Network: "tcp",
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+type sessionBuilder struct {
+	instance *Session
+}
+
+func newSessionBuilder() *sessionBuilder {
+	return &sessionBuilder{
+		instance: &Session{},
+	}
+}
+
+func newSessionBuilderFrom(src *Session) *sessionBuilder {
+	if src == nil {
+		return &sessionBuilder{
+			instance: &Session{},
+		}
+	}
+	instance := *src
+	return &sessionBuilder{instance: &instance}
+}
+
+func (s Session) toBuilder() *sessionBuilder {
+	return newSessionBuilderFrom(&s)
+}
+
+func (b *sessionBuilder) Token(v string) *sessionBuilder {
+	b.instance.Token = v
+	return b
+}
+func (b *sessionBuilder) Id(v int) *sessionBuilder {
+	b.instance.id = v
+	return b
+}
+
+func (b *sessionBuilder) Build() *Session {
+	return b.instance
+}
+
 type SimpleBuilder struct {
 	instance *Simple
 }
@@ -356,6 +1301,20 @@ func NewSimpleBuilder() *SimpleBuilder {
 	}
 }
 
+func NewSimpleBuilderFrom(src *Simple) *SimpleBuilder {
+	if src == nil {
+		return &SimpleBuilder{
+			instance: &Simple{},
+		}
+	}
+	instance := *src
+	return &SimpleBuilder{instance: &instance}
+}
+
+func (s Simple) ToBuilder() *SimpleBuilder {
+	return NewSimpleBuilderFrom(&s)
+}
+
 func (b *SimpleBuilder) Name(v string) *SimpleBuilder {
 	b.instance.Name = v
 	return b
@@ -373,6 +1332,60 @@ func (b *SimpleBuilder) Build() *Simple {
 	return b.instance
 }
 
+type CredentialsBuilder struct {
+	instance *Credentials
+}
+
+type CredentialsUsernameStep interface {
+	Username(v string) CredentialsPasswordStep
+}
+
+type CredentialsPasswordStep interface {
+	Password(v string) *CredentialsBuilder
+}
+
+type stagedCredentialsBuilder struct {
+	instance *Credentials
+}
+
+func NewCredentialsBuilder() CredentialsUsernameStep {
+	return &stagedCredentialsBuilder{
+		instance: &Credentials{},
+	}
+}
+
+func (b *stagedCredentialsBuilder) Username(v string) CredentialsPasswordStep {
+	b.instance.Username = v
+	return b
+}
+func (b *stagedCredentialsBuilder) Password(v string) *CredentialsBuilder {
+	b.instance.Password = v
+	return &CredentialsBuilder{instance: b.instance}
+}
+
+func NewCredentialsBuilderFrom(src *Credentials) *CredentialsBuilder {
+	if src == nil {
+		return &CredentialsBuilder{
+			instance: &Credentials{},
+		}
+	}
+	instance := *src
+	return &CredentialsBuilder{instance: &instance}
+}
+
+func (s Credentials) ToBuilder() *CredentialsBuilder {
+	return NewCredentialsBuilderFrom(&s)
+}
+
+func (b *CredentialsBuilder) Realm(v string) *CredentialsBuilder {
+	b.instance.Realm = v
+	return b
+}
+
+func (b *CredentialsBuilder) Build() *Credentials {
+	return b.instance
+}
+
 type UserBuilder struct {
 	instance *User
 }
@@ -383,6 +1396,21 @@ func NewUserBuilder() *UserBuilder {
 	}
 }
 
+func NewUserBuilderFrom(src *User) *UserBuilder {
+	if src == nil {
+		return &UserBuilder{
+			instance: &User{},
+		}
+	}
+	instance := *src
+	instance.Tags = instance.Tags[:len(instance.Tags):len(instance.Tags)]
+	return &UserBuilder{instance: &instance}
+}
+
+func (s User) ToBuilder() *UserBuilder {
+	return NewUserBuilderFrom(&s)
+}
+
 func (b *UserBuilder) Name(v string) *UserBuilder {
 	b.instance.Name = v
 	return b
@@ -396,6 +1424,96 @@ func (b *UserBuilder) Tags(v []string) *UserBuilder {
 	return b
 }
 
+func (b *UserBuilder) AddTags(v ...string) *UserBuilder {
+	b.instance.Tags = append(b.instance.Tags, v...)
+	return b
+}
+
 func (b *UserBuilder) Build() *User {
 	return b.instance
 }
+
+type EndpointBuilder struct {
+	instance *Endpoint
+}
+
+type EndpointHostStep interface {
+	Host(v string) *EndpointBuilder
+}
+
+type stagedEndpointBuilder struct {
+	instance *Endpoint
+}
+
+func NewEndpointBuilder() EndpointHostStep {
+	return &stagedEndpointBuilder{
+		instance: &Endpoint{
+			Port:    443,
+			Timeout: time.Second * 30,
+		},
+	}
+}
+
+func (b *stagedEndpointBuilder) Host(v string) *EndpointBuilder {
+	b.instance.Host = v
+	return &EndpointBuilder{instance: b.instance}
+}
+
+func NewEndpointBuilderFrom(src *Endpoint) *EndpointBuilder {
+	if src == nil {
+		return &EndpointBuilder{
+			instance: &Endpoint{
+				Port:    443,
+				Timeout: time.Second * 30,
+			},
+		}
+	}
+	instance := *src
+	instance.Aliases = instance.Aliases[:len(instance.Aliases):len(instance.Aliases)]
+	return &EndpointBuilder{instance: &instance}
+}
+
+func (s Endpoint) ToBuilder() *EndpointBuilder {
+	return NewEndpointBuilderFrom(&s)
+}
+
+func (b *EndpointBuilder) Port(v int) *EndpointBuilder {
+	b.instance.Port = v
+	return b
+}
+func (b *EndpointBuilder) Timeout(v time.Duration) *EndpointBuilder {
+	b.instance.Timeout = v
+	return b
+}
+func (b *EndpointBuilder) Aliases(v []string) *EndpointBuilder {
+	b.instance.Aliases = v
+	return b
+}
+
+func (b *EndpointBuilder) AddAliases(v ...string) *EndpointBuilder {
+	b.instance.Aliases = append(b.instance.Aliases, v...)
+	return b
+}
+
+func (b *EndpointBuilder) Build() (*Endpoint, error) {
+	var errs []error
+	if b.instance.Host == "" {
+		errs = append(errs, errors.New("Endpoint.Host must be set"))
+	}
+	if len(b.instance.Host) > 253 {
+		errs = append(errs, errors.New("Endpoint.Host must be of length at most 253"))
+	}
+	if b.instance.Port < 1 {
+		errs = append(errs, errors.New("Endpoint.Port must be at least 1"))
+	}
+	if b.instance.Port > 65535 {
+		errs = append(errs, errors.New("Endpoint.Port must be at most 65535"))
+	}
+	if err := b.instance.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return b.instance, nil
+}
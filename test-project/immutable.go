@@ -0,0 +1,15 @@
+package main
+
+//gobok:builder:immutable
+type Request struct {
+	Method string `gobok:"default=\"GET\""`
+	Host   string
+	Path   string
+}
+
+//gobok:builder:immutable
+//gobok:builder:validate
+type Route struct {
+	Pattern string `gobok:"required"`
+	Handler string
+}
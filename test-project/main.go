@@ -1,26 +1,25 @@
 package main
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"time"
 )
 
 func main() {
-	// Create an address
-	address := NewAddressBuilder().
-		Street("123 Main St").
-		City("New York").
-		Country("USA").
-		Build()
-
-	// Create a contact with the address
-	phone := "+1-555-1234"
+	// Create a contact, building its address in place
 	contact := NewContactBuilder().
 		Email("john@example.com").
-		Phone(&phone).
-		Address(address).
+		PhoneValue("+1-555-1234").
+		AddressWith(func(address *AddressBuilder) {
+			address.
+				Street("123 Main St").
+				City("New York").
+				Country("USA")
+		}).
 		IsActive(true).
 		Build()
+	address := contact.Address
 
 	// Create settings map
 	settings := map[string]string{
@@ -42,6 +41,7 @@ func main() {
 		Age(30).
 		Contacts([]Contact{*contact}).
 		Metadata(metadata).
+		PutMetadata("source", "signup").
 		Settings(&settings).
 		CreatedAt(now).
 		UpdatedAt(&now).
@@ -122,6 +122,8 @@ func main() {
 		IntArray([]int{1, 2, 3}).
 		StringArray([]string{"one", "two", "three"}).
 		StructArray([]NestedStruct{*nested}).
+		FixedArray([4]int{1, 2, 3, 4}).
+		HashArray(sha256.Sum256([]byte("gobok"))).
 
 		// Map types
 		SimpleMap(simpleMap).
@@ -134,10 +136,97 @@ func main() {
 		SendChan(sendChan).
 		ReceiveChan(receiveChan).
 
+		// Func types
+		Handler(func(name string, args ...interface{}) error { return nil }).
+		Formatter(func(format string, args ...int) (string, error) { return format, nil }).
+
 		// Nested struct
 		NestedStruct(*nested).
 		Build()
 
 	// Print the results
 	fmt.Printf("AllTypes: %+v\n", allTypes)
+
+	// Required fields must be set, in order, before Build is reachable
+	credentials := NewCredentialsBuilder().
+		Username("admin").
+		Password("secret").
+		Realm("internal").
+		Build()
+
+	fmt.Printf("Credentials: %+v\n", credentials)
+
+	// Validating builders report every failed rule at once
+	_, err := NewEndpointBuilder().
+		Host("localhost").
+		Port(0).
+		Build()
+
+	fmt.Printf("Endpoint error: %v\n", err)
+
+	// Functional options start from the declared defaults
+	dialer := NewDialer(
+		WithKeepAlive(time.Minute),
+		WithRetries(3),
+	)
+
+	fmt.Printf("Dialer: %+v\n", dialer)
+
+	// Immutable builders can be reused as prototypes
+	base := NewRequestBuilder().Host("example.com")
+	requestA := base.Path("/a").Build()
+	requestB := base.Path("/b").Build()
+
+	fmt.Printf("Requests: %+v %+v\n", requestA, requestB)
+
+	route, err := NewRouteBuilder().Pattern("/users").Handler("users").Build()
+	fmt.Printf("Route: %+v %v\n", route, err)
+
+	// Clones share no memory with the original
+	burst := 10
+	original := NewServiceConfigBuilder().
+		Name("api").
+		Backends([]string{"a", "b"}).
+		Labels(map[string][]string{"tier": {"web"}}).
+		Limits(&Limits{Burst: &burst, PerRoute: map[string]int{"/": 1}}).
+		Build()
+	clone := original.Clone()
+	clone.Backends[0] = "c"
+	clone.Labels["tier"][0] = "db"
+	*clone.Limits.Burst = 20
+
+	fmt.Printf("Original: %v %v %d\n", original.Backends, original.Labels, *original.Limits.Burst)
+	fmt.Printf("Clone: %v %v %d\n", clone.Backends, clone.Labels, *clone.Limits.Burst)
+
+	// Embedded structs can be set as a whole or, when flattened, field by field
+	admin := NewAdminBuilder().
+		User(User{Name: "root"}).
+		Level(1).
+		Build()
+	moderator := NewModeratorBuilder().
+		Name("mod").
+		Age(40).
+		City("Paris").
+		Build()
+	guestBase := NewGuestBuilder().City("Rome")
+	guest := guestBase.Street("Via Roma").Build()
+
+	fmt.Printf("Admin: %+v\n", admin)
+	fmt.Printf("Moderator: %+v %+v\n", moderator, moderator.User)
+	fmt.Printf("Guest: %+v %+v\n", guest.Address, guestBase.Build().Address)
+
+	// Private builders are only reachable from within the package
+	session := newSessionBuilder().Token("abc").Id(7).Build()
+	fmt.Printf("Session: %+v\n", session)
+
+	// Existing values can be turned back into builders and tweaked
+	relocated := contact.ToBuilder().
+		Email("john@example.org").
+		Build()
+	older := NewPersonBuilderFrom(&Person{Name: "Ann", Age: 30}).
+		Age(31).
+		Build()
+
+	fmt.Printf("Relocated: %+v\n", relocated)
+	fmt.Printf("Older: %+v\n", older)
 }
@@ -0,0 +1,10 @@
+package main
+
+import "time"
+
+//gobok:options
+type Dialer struct {
+	Network   string `gobok:"default=\"tcp\""`
+	KeepAlive time.Duration
+	Retries   int
+}
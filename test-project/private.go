@@ -0,0 +1,8 @@
+package main
+
+//gobok:builder:private
+type Session struct {
+	Token string
+	id    int `gobok:"include"`
+	cache map[string]string
+}
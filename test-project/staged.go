@@ -0,0 +1,8 @@
+package main
+
+//gobok:builder
+type Credentials struct {
+	Username string `gobok:"required"`
+	Password string `gobok:"required"`
+	Realm    string
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+//gobok:builder:validate
+type Endpoint struct {
+	Host    string        `gobok:"required,max=253"`
+	Port    int           `gobok:"min=1,max=65535,default=443"`
+	Timeout time.Duration `gobok:"default=time.Second * 30"`
+	Aliases []string
+}
+
+// Validate rejects endpoints that point back at the local machine
+func (e *Endpoint) Validate() error {
+	if e.Host == "localhost" {
+		return errors.New("endpoint must not be local")
+	}
+	return nil
+}